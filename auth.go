@@ -0,0 +1,111 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// authTimeout is the time limit of automatic authentication.
+const authTimeout = 10 * time.Second
+
+// authState is the state of authentication on a connection.
+type authState struct {
+	challenge string
+	done      chan struct{} // closed when authenticated
+	once      sync.Once
+}
+
+func newAuthState() *authState {
+	return &authState{done: make(chan struct{})}
+}
+
+func (s *authState) authenticated() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// NewAuthEvent creates an unsigned authentication event defined in NIP-42
+// for the relay server of url and the challenge sent by it.
+func NewAuthEvent(url, challenge string) *Event {
+	return &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindClientAuthentication,
+		Tags: []Tag{
+			{"relay", url},
+			{"challenge", challenge},
+		},
+		Content: "",
+	}
+}
+
+// AuthChallenge returns the latest challenge sent by the relay server on the current connection.
+// It returns an empty string if the relay server has not requested authentication.
+func (c *Client) AuthChallenge() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.auth.challenge
+}
+
+// Authenticate responds to the latest challenge with an authentication event signed by signer
+// and waits for the relay server to accept it.
+func (c *Client) Authenticate(ctx context.Context, signer Signer) error {
+	c.mu.Lock()
+	auth := c.auth
+	challenge := auth.challenge
+	c.mu.Unlock()
+	if challenge == "" {
+		return errors.New("no auth challenge from relay server")
+	}
+
+	event := NewAuthEvent(c.url, challenge)
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to sign auth event: %w", err)
+	}
+	result, err := c.send(ctx, event.ID, &AuthMessage{Event: event})
+	if err != nil {
+		return err
+	}
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	auth.authenticated()
+	return nil
+}
+
+// WaitAuth blocks until the client is authenticated on the current connection.
+// It is useful to wait for automatic authentication enabled by WithAuthSigner
+// before publishing events or subscribing to relay servers requiring authentication.
+func (c *Client) WaitAuth(ctx context.Context) error {
+	c.mu.Lock()
+	auth := c.auth
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("not authenticated: %w", ctx.Err())
+	case <-auth.done:
+		return nil
+	}
+}
+
+func (c *Client) handleAuthMessage(m *AuthMessage) error {
+	if m.Challenge == "" {
+		return errors.New("missing auth challenge")
+	}
+
+	c.mu.Lock()
+	c.auth.challenge = m.Challenge
+	c.mu.Unlock()
+
+	if c.authSigner != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(c.ctx, authTimeout)
+			defer cancel()
+			_ = c.Authenticate(ctx, c.authSigner)
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// newAuthTestServer returns a relay server that requests authentication on connection
+// and accepts events and subscriptions only from authenticated clients.
+// If lazy is true, it requests authentication when it refuses a subscription instead.
+func newAuthTestServer(challenge string, lazy bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		if challenge != "" && !lazy {
+			if err := wsjson.Write(ctx, conn, []any{"AUTH", challenge}); err != nil {
+				return
+			}
+		}
+
+		var authenticated bool
+		for {
+			var b json.RawMessage
+			if err := wsjson.Read(ctx, conn, &b); err != nil {
+				return
+			}
+			typ, err := ParseMessageType(b)
+			if err != nil {
+				return
+			}
+
+			switch typ {
+			case MessageTypeAuth:
+				var m AuthMessage
+				if err := m.UnmarshalJSON(b); err != nil || m.Event == nil {
+					return
+				}
+				ok, message := true, ""
+				if err := m.Event.Verify(); err != nil {
+					ok, message = false, "invalid: bad signature"
+				} else if m.Event.Kind != EventKindClientAuthentication || !hasTag(m.Event.Tags, "challenge", challenge) {
+					ok, message = false, "invalid: bad challenge"
+				}
+				authenticated = ok
+				if err := wsjson.Write(ctx, conn, []any{"OK", m.Event.ID, ok, message}); err != nil {
+					return
+				}
+			case MessageTypeEvent:
+				var m EventMessage
+				if err := m.UnmarshalJSON(b); err != nil {
+					return
+				}
+				message := ""
+				if !authenticated {
+					message = "auth-required: we only accept events from authenticated users"
+				}
+				if err := wsjson.Write(ctx, conn, []any{"OK", m.Event.ID, authenticated, message}); err != nil {
+					return
+				}
+			case MessageTypeReq:
+				var m ReqMessage
+				if err := m.UnmarshalJSON(b); err != nil {
+					return
+				}
+				if authenticated {
+					if err := wsjson.Write(ctx, conn, []any{"EOSE", m.SubscriptionID}); err != nil {
+						return
+					}
+					continue
+				}
+				if err := wsjson.Write(ctx, conn, []any{"CLOSED", m.SubscriptionID, "auth-required: we only serve authenticated users"}); err != nil {
+					return
+				}
+				if challenge != "" && lazy {
+					if err := wsjson.Write(ctx, conn, []any{"AUTH", challenge}); err != nil {
+						return
+					}
+				}
+			}
+		}
+	}))
+}
+
+func hasTag(tags []Tag, name, value string) bool {
+	for _, tag := range tags {
+		if len(tag) >= 2 && tag[0] == name && tag[1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{key: key}
+}
+
+func TestClientWithAuthSigner(t *testing.T) {
+	server := newAuthTestServer("challenge-string", false)
+	defer server.Close()
+
+	signer := newTestSigner(t)
+	client, err := NewClient(server.URL, WithAuthSigner(signer))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitAuth(ctx); err != nil {
+		t.Fatalf("client.WaitAuth() failed: %s", err)
+	}
+	if challenge := client.AuthChallenge(); challenge != "challenge-string" {
+		t.Errorf("client.AuthChallenge() returned %s", challenge)
+	}
+
+	event := &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+	result, err := client.SignAndPublish(ctx, signer, event)
+	if err != nil {
+		t.Fatalf("client.SignAndPublish() failed: %s", err)
+	}
+	if !result.OK {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestClientAuthenticate(t *testing.T) {
+	server := newAuthTestServer("challenge-string", false)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	signer := newTestSigner(t)
+	event := &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+	result, err := client.SignAndPublish(ctx, signer, event)
+	if err != nil {
+		t.Fatalf("client.SignAndPublish() failed: %s", err)
+	}
+	if result.OK {
+		t.Errorf("event is accepted before authentication")
+	}
+
+	if err := client.Authenticate(ctx, signer); err != nil {
+		t.Fatalf("client.Authenticate() failed: %s", err)
+	}
+	if err := client.WaitAuth(ctx); err != nil {
+		t.Fatalf("client.WaitAuth() failed: %s", err)
+	}
+
+	result, err = client.Publish(ctx, event)
+	if err != nil {
+		t.Fatalf("client.Publish() failed: %s", err)
+	}
+	if !result.OK {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestClientAuthenticateWithoutChallenge(t *testing.T) {
+	server := newAuthTestServer("", false)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.Authenticate(ctx, newTestSigner(t)); err == nil {
+		t.Errorf("client.Authenticate() succeeded without challenge")
+	}
+	if err := client.WaitAuth(ctx); err == nil {
+		t.Errorf("client.WaitAuth() succeeded without authentication")
+	}
+}
+
+func TestClientWithAuthSignerSubscribe(t *testing.T) {
+	server := newAuthTestServer("challenge-string", true)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithAuthSigner(newTestSigner(t)))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+
+	// the request closed with auth-required is re-sent after authentication
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- sub.Receive(ctx, func(context.Context, *Event) {})
+	}()
+	select {
+	case <-sub.EOSE():
+	case err := <-errChan:
+		t.Fatalf("sub.Receive() returned %v", err)
+	case <-ctx.Done():
+		t.Fatal("EOSE is not received")
+	}
+}
+
+func TestClientWithAuthSignerReconnect(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+		n := connections.Add(1)
+
+		var authenticated bool
+		for {
+			var b json.RawMessage
+			if err := wsjson.Read(ctx, conn, &b); err != nil {
+				return
+			}
+			typ, err := ParseMessageType(b)
+			if err != nil {
+				return
+			}
+
+			switch typ {
+			case MessageTypeAuth:
+				var m AuthMessage
+				if err := m.UnmarshalJSON(b); err != nil || m.Event == nil {
+					return
+				}
+				authenticated = true
+				if err := wsjson.Write(ctx, conn, []any{"OK", m.Event.ID, true, ""}); err != nil {
+					return
+				}
+			case MessageTypeReq:
+				var m ReqMessage
+				if err := m.UnmarshalJSON(b); err != nil {
+					return
+				}
+				if !authenticated {
+					// request authentication only when refusing subscriptions
+					if err := wsjson.Write(ctx, conn, []any{"CLOSED", m.SubscriptionID, "auth-required: we only serve authenticated users"}); err != nil {
+						return
+					}
+					if err := wsjson.Write(ctx, conn, []any{"AUTH", "challenge-" + strconv.Itoa(int(n))}); err != nil {
+						return
+					}
+					continue
+				}
+				if err := wsjson.Write(ctx, conn, []any{"EOSE", m.SubscriptionID}); err != nil {
+					return
+				}
+				if n == 1 {
+					conn.Close(websocket.StatusGoingAway, "")
+					return
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithAuthSigner(newTestSigner(t)), WithReconnect(Backoff{Initial: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	// the request is re-sent without waiting for a challenge which is sent only on requests
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	go func() {
+		_ = sub.Receive(ctx, func(context.Context, *Event) {})
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sub.EOSE():
+		case <-ctx.Done():
+			t.Fatalf("EOSE %d is not received", i+1)
+		}
+	}
+	if n := connections.Load(); n != 2 {
+		t.Errorf("unexpected number of connections: %d", n)
+	}
+}
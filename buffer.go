@@ -0,0 +1,141 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultBufferSize is the default number of events buffered for each subscription.
+const defaultBufferSize = 1024
+
+// ErrBufferOverflow is returned by Subscription.Receive
+// when the subscription is closed by OverflowClose.
+var ErrBufferOverflow = errors.New("subscription buffer overflow")
+
+// An OverflowPolicy decides what to do with an event received
+// while the buffer of the subscription is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the buffer to have room.
+	// No event is lost, but reading messages from the relay server stops meanwhile,
+	// which also delays other subscriptions and command results of the client.
+	// The callback of Receive must not wait for a response from the same client,
+	// such as the result of Publish, as the response is not read while blocked.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered event to make room.
+	OverflowDropOldest
+	// OverflowDropNewest drops the received event.
+	OverflowDropNewest
+	// OverflowClose closes the subscription, and Receive returns ErrBufferOverflow.
+	OverflowClose
+)
+
+// A SubscriptionOption configures a subscription.
+type SubscriptionOption func(*subscriptionOptions)
+
+type subscriptionOptions struct {
+	bufferSize int
+	overflow   OverflowPolicy
+}
+
+func newSubscriptionOptions(opts []SubscriptionOption) *subscriptionOptions {
+	o := &subscriptionOptions{
+		bufferSize: defaultBufferSize,
+		overflow:   OverflowDropOldest,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithBufferSize sets the number of events buffered for the subscription
+// while the callback of Receive is busy. The default is 1024.
+func WithBufferSize(n int) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		if n < 0 {
+			n = 0
+		}
+		o.bufferSize = n
+	}
+}
+
+// WithOverflowPolicy sets the policy applied when the buffer of the subscription is full.
+// The default is OverflowDropOldest, which never blocks reading messages from the relay server.
+func WithOverflowPolicy(policy OverflowPolicy) SubscriptionOption {
+	return func(o *subscriptionOptions) {
+		o.overflow = policy
+	}
+}
+
+// deliver passes the event to the subscription following its overflow policy.
+// It returns false if the event is dropped.
+func (c *Client) deliver(id string, g *subChannelGroup, event *Event) bool {
+	switch g.overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case g.eventChan <- event:
+				return true
+			default:
+			}
+			select {
+			case <-g.eventChan:
+				g.dropped.Add(1)
+			default:
+				// the buffer has no event to drop
+				g.dropped.Add(1)
+				return false
+			}
+		}
+	case OverflowDropNewest:
+		select {
+		case g.eventChan <- event:
+			return true
+		default:
+			g.dropped.Add(1)
+			return false
+		}
+	case OverflowClose:
+		select {
+		case g.eventChan <- event:
+			return true
+		default:
+			g.dropped.Add(1)
+			c.closeOverflowed(id, g)
+			return false
+		}
+	default:
+		select {
+		case g.eventChan <- event:
+			return true
+		case <-g.done:
+			// the subscription has been closed
+			return false
+		case <-c.ctx.Done():
+			return false
+		}
+	}
+}
+
+// closeOverflowed closes the subscription whose buffer has overflowed.
+func (c *Client) closeOverflowed(id string, g *subChannelGroup) {
+	if _, ok := c.subMap.LoadAndDelete(id); !ok {
+		return
+	}
+	g.close()
+
+	select {
+	case g.errChan <- ErrBufferOverflow:
+	default:
+	}
+
+	// Receive returns without sending CLOSE
+	go func() {
+		ctx, cancel := context.WithTimeout(c.ctx, time.Second)
+		defer cancel()
+		_ = c.writeMessage(ctx, &CloseMessage{SubscriptionID: id})
+	}()
+}
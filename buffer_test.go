@@ -0,0 +1,281 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// newBurstTestServer returns a relay server that sends n events at once followed by EOSE
+// in response to every request, and accepts every event.
+func newBurstTestServer(t *testing.T, n int) *httptest.Server {
+	t.Helper()
+	key, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	events := make([]*Event, n)
+	for i := range events {
+		events[i] = &Event{
+			CreatedAt: createdAt + int64(i),
+			Kind:      EventKindTextNote,
+			Tags:      []Tag{},
+			Content:   fmt.Sprintf("text note %d", i),
+		}
+		if err := events[i].SignWithKey(&key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ, subscriptionID string
+			if err := json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ == "EVENT" {
+				var event Event
+				if err := json.Unmarshal(message[1], &event); err != nil {
+					return
+				}
+				if err := wsjson.Write(ctx, conn, []any{"OK", event.ID, true, ""}); err != nil {
+					return
+				}
+				continue
+			}
+			if typ != "REQ" {
+				continue
+			}
+			if err := json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+			for _, event := range events {
+				if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+					return
+				}
+			}
+			if err := wsjson.Write(ctx, conn, []any{"EOSE", subscriptionID}); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+// receiveBurst receives events with a callback blocked until EOSE is received.
+func receiveBurst(t *testing.T, client *Client, opts ...SubscriptionOption) (*Subscription, []string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := client.Subscribe(ctx, []Filter{{}}, opts...)
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+
+	unblock := make(chan struct{})
+	go func() {
+		select {
+		case <-sub.EOSE():
+		case <-ctx.Done():
+		}
+		close(unblock)
+		// wait for buffered events to be consumed
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	err = sub.Receive(ctx, func(ctx context.Context, event *Event) {
+		select {
+		case <-unblock:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event.Content)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	return sub, received, err
+}
+
+func TestSubscriptionOverflowBlock(t *testing.T) {
+	server := newBurstTestServer(t, 10)
+	defer server.Close()
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{}}, WithBufferSize(2), WithOverflowPolicy(OverflowBlock))
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		// a slow callback
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event.Content)
+		if len(received) == 10 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("sub.Receive() failed: %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 10 {
+		t.Errorf("unexpected number of received events: %d", len(received))
+	}
+	if n := sub.Dropped(); n != 0 {
+		t.Errorf("sub.Dropped() returned %d", n)
+	}
+}
+
+func TestSubscriptionOverflowDrop(t *testing.T) {
+	server := newBurstTestServer(t, 10)
+	defer server.Close()
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	t.Run("drop newest", func(t *testing.T) {
+		sub, received, err := receiveBurst(t, client, WithBufferSize(2), WithOverflowPolicy(OverflowDropNewest))
+		if err != nil {
+			t.Fatalf("sub.Receive() failed: %s", err)
+		}
+		// the callback holds one event and the buffer holds two
+		if len(received) > 3 || int64(len(received))+sub.Dropped() != 10 {
+			t.Errorf("unexpected events: received %v, dropped %d", received, sub.Dropped())
+		}
+		if received[0] != "text note 0" {
+			t.Errorf("oldest event is dropped: %v", received)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		sub, received, err := receiveBurst(t, client, WithBufferSize(2), WithOverflowPolicy(OverflowDropOldest))
+		if err != nil {
+			t.Fatalf("sub.Receive() failed: %s", err)
+		}
+		if len(received) > 3 || int64(len(received))+sub.Dropped() != 10 {
+			t.Errorf("unexpected events: received %v, dropped %d", received, sub.Dropped())
+		}
+		if received[len(received)-1] != "text note 9" {
+			t.Errorf("newest event is dropped: %v", received)
+		}
+	})
+}
+
+func TestSubscriptionOverflowClose(t *testing.T) {
+	server := newBurstTestServer(t, 10)
+	defer server.Close()
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	sub, _, err := receiveBurst(t, client, WithBufferSize(2), WithOverflowPolicy(OverflowClose))
+	if !errors.Is(err, ErrBufferOverflow) {
+		t.Fatalf("sub.Receive() returned %v, expected buffer overflow", err)
+	}
+	if sub.Dropped() == 0 {
+		t.Errorf("no event is dropped")
+	}
+	if _, ok := client.subMap.Load(sub.ID()); ok {
+		t.Errorf("closed subscription is still registered")
+	}
+}
+
+func TestSubscriptionPublishInCallback(t *testing.T) {
+	server := newBurstTestServer(t, 10)
+	defer server.Close()
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+	signer := newTestSigner(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{}}, WithBufferSize(2))
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		handled int
+	)
+	err = sub.Receive(ctx, func(ctx context.Context, event *Event) {
+		// publishing waits for the OK message read after the burst of events
+		reply := &Event{
+			CreatedAt: time.Now().Unix(),
+			Kind:      EventKindReaction,
+			Tags:      []Tag{{"e", event.ID}},
+			Content:   "+",
+		}
+		if _, err := client.SignAndPublish(ctx, signer, reply); err != nil {
+			t.Errorf("client.SignAndPublish() failed: %s", err)
+			cancel()
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+		if event.Content == "text note 9" {
+			// the last event is never dropped
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("sub.Receive() failed: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if handled == 0 {
+		t.Errorf("no event is handled")
+	}
+}
@@ -7,73 +7,216 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"nhooyr.io/websocket"
 )
 
 type subChannelGroup struct {
-	eventChan chan<- *Event
+	filters   []Filter
+	eventChan chan *Event
 	eoseChan  chan<- struct{}
+	errChan   chan<- error
+	overflow  OverflowPolicy
+	dropped   *atomic.Int64
+
+	done      chan struct{} // closed when unregistered
+	closeOnce sync.Once
+	release   func() // releases the subscription slot
+
+	mu sync.Mutex
+	// backfill is true until EOSE is received for the latest request,
+	// while stored events are received newest first.
+	backfill bool
+	// lastSeen is the largest created_at of events received so far,
+	// advanced by stored events only after EOSE.
+	lastSeen int64
+	// seen maps IDs of received events to their created_at.
+	// It keeps events received during backfill, which are sent again
+	// if the connection is lost before EOSE, and events created at lastSeen,
+	// which are sent again after reconnection as since of resumed filters is inclusive.
+	seen map[string]int64
+}
+
+// close marks the subscription unregistered from the client.
+func (g *subChannelGroup) close() {
+	g.closeOnce.Do(func() {
+		close(g.done)
+		g.release()
+	})
+}
+
+// observe records that the event has been received.
+// It reports false if the event is a duplicate sent again after reconnection.
+func (g *subChannelGroup) observe(event *Event) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[event.ID]; ok {
+		return false
+	}
+	if g.seen == nil {
+		g.seen = make(map[string]int64)
+	}
+	if g.backfill {
+		g.seen[event.ID] = event.CreatedAt
+		return true
+	}
+	if event.CreatedAt > g.lastSeen {
+		g.lastSeen = event.CreatedAt
+		g.seen = make(map[string]int64)
+	}
+	if event.CreatedAt == g.lastSeen {
+		g.seen[event.ID] = event.CreatedAt
+	}
+	return true
+}
+
+// endBackfill records that EOSE has been received and advances lastSeen to the stored events.
+func (g *subChannelGroup) endBackfill() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.backfill = false
+	for _, createdAt := range g.seen {
+		if createdAt > g.lastSeen {
+			g.lastSeen = createdAt
+		}
+	}
+	for id, createdAt := range g.seen {
+		if createdAt < g.lastSeen {
+			delete(g.seen, id)
+		}
+	}
+}
+
+// resumeFilters returns the filters to re-send after reconnection
+// and starts backfill of the resumed request.
+// Since of each filter is advanced to the last seen event.
+// Events already received are sent again and dropped by observe.
+func (g *subChannelGroup) resumeFilters() []Filter {
+	g.mu.Lock()
+	g.backfill = true
+	since := g.lastSeen
+	g.mu.Unlock()
+	if since == 0 {
+		return g.filters
+	}
+	filters := make([]Filter, len(g.filters))
+	for i, f := range g.filters {
+		if f.Since < since {
+			f.Since = since
+		}
+		filters[i] = f
+	}
+	return filters
 }
 
 type eventChannelGroup struct {
 	okChan chan<- *CommandResult
 }
 
+type countChannelGroup struct {
+	resultChan chan<- *CountResult
+	errChan    chan<- error
+}
+
 // A Client is a Nostr client that connects to a relay server.
 type Client struct {
-	conn *websocket.Conn
+	url string
 
-	done chan struct{}
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	state  ConnectionState
+	closed bool
+	auth   *authState
+
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	noticeChan chan string
 	subMap     sync.Map // map[string]*subChannelGroup
 	eventMap   sync.Map // map[string]*eventChannelGroup
+	countMap   sync.Map // map[string]*countChannelGroup
 
 	closeOnce sync.Once
 	closeErr  error
+
+	verifyEvents        bool
+	invalidEventHandler func(subID string, event *Event, err error)
+	backoff             *Backoff
+	stateHandler        func(ConnectionState)
+	authSigner          Signer
+	fetchInfo           bool
+	limitation          *RelayLimitation
+	slots               *subscriptionSlots
+	rejectionErrors     bool
 }
 
 // NewClient creates a new Nostr client.
 // It establishes a websocket connection to the relay server.
-func NewClient(url string) (*Client, error) {
-	ctx := context.Background()
-	conn, _, err := websocket.Dial(ctx, url, nil)
+func NewClient(url string, opts ...ClientOption) (*Client, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := dial(ctx, url)
 	if err != nil {
-		return nil, fmt.Errorf("websocket connection error: %w", err)
+		cancel()
+		return nil, err
 	}
-	// disable read limit
-	conn.SetReadLimit(math.MaxInt64 - 1)
 
-	done := make(chan struct{})
 	client := &Client{
+		url:        url,
 		conn:       conn,
-		done:       done,
+		state:      ConnectionStateConnected,
+		auth:       newAuthState(),
+		ctx:        ctx,
+		cancel:     cancel,
 		noticeChan: make(chan string, 100),
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.fetchInfo && client.limitation == nil {
+		client.fetchLimitation()
+	}
+	client.slots = newSubscriptionSlots(client.limitation)
 
-	go func() {
-	outer:
-		for {
-			select {
-			case <-done:
-				break outer
-			default:
-			}
-
-			if err := client.readMessage(ctx); err != nil {
-				continue
-			}
-		}
-	}()
+	go client.readLoop()
 
 	return client, nil
 }
 
+func dial(ctx context.Context, url string) (*websocket.Conn, error) {
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection error: %w", err)
+	}
+	// disable read limit
+	conn.SetReadLimit(math.MaxInt64 - 1)
+	return conn, nil
+}
+
 // Publish submits an event to the relay server and waits for the command result.
+// If the event exceeds the limitation of the relay server, it returns a *LimitError.
+// If the client is created with WithRejectionErrors, it also returns a *RejectedError
+// along with the result when the relay server rejects the event.
 func (c *Client) Publish(ctx context.Context, event *Event) (*CommandResult, error) {
-	id := event.ID
+	if err := c.checkEvent(event); err != nil {
+		return nil, err
+	}
+	result, err := c.send(ctx, event.ID, &EventMessage{Event: event})
+	if err != nil {
+		return nil, err
+	}
+	if c.rejectionErrors {
+		return result, result.Err()
+	}
+	return result, nil
+}
+
+// send writes a message carrying the event of id and waits for the command result.
+func (c *Client) send(ctx context.Context, id string, message json.Marshaler) (*CommandResult, error) {
 	okChan := make(chan *CommandResult, 1)
 
 	c.eventMap.Store(id, &eventChannelGroup{
@@ -81,7 +224,7 @@ func (c *Client) Publish(ctx context.Context, event *Event) (*CommandResult, err
 	})
 	defer c.eventMap.Delete(id)
 
-	if err := c.writeMessage(ctx, &EventMessage{Event: event}); err != nil {
+	if err := c.writeMessage(ctx, message); err != nil {
 		return nil, err
 	}
 
@@ -93,22 +236,95 @@ func (c *Client) Publish(ctx context.Context, event *Event) (*CommandResult, err
 	}
 }
 
+// SignAndPublish signs the event with signer and submits it to the relay server.
+func (c *Client) SignAndPublish(ctx context.Context, signer Signer, event *Event) (*CommandResult, error) {
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to sign event: %w", err)
+	}
+	return c.Publish(ctx, event)
+}
+
 // Subscribe creates a subscription to the relay server with the given filters.
-func (c *Client) Subscribe(ctx context.Context, filters []Filter) (*Subscription, error) {
+//
+// If the client enforces the limitation of the relay server,
+// the limit of filters is clamped to max_limit, filters are split into requests of at most max_filters,
+// and requests wait to be sent while max_subscriptions subscriptions are active.
+// If the relay server closes any of the split requests, the whole subscription is closed with the error.
+//
+// Received events are buffered while the callback of Receive is busy.
+// The size of the buffer and the policy applied when it is full are configured with opts.
+func (c *Client) Subscribe(ctx context.Context, filters []Filter, opts ...SubscriptionOption) (*Subscription, error) {
 	if len(filters) == 0 {
 		return nil, errors.New("at least one filter is required")
 	}
+	o := newSubscriptionOptions(opts)
+
+	groups, err := c.limitFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 1 {
+		return c.subscribe(groups[0], o, func(ctx context.Context) (func(), error) {
+			releases, err := c.slots.acquire(ctx, 1)
+			if err != nil {
+				return nil, err
+			}
+			return releases[0], nil
+		}), nil
+	}
+
+	// slots acquired for all the groups at once are handed to each of them
+	releases := make(chan func(), len(groups))
+	subs := make([]*Subscription, len(groups))
+	for i, filters := range groups {
+		subs[i] = c.subscribe(filters, o, func(context.Context) (func(), error) {
+			return <-releases, nil
+		})
+	}
+	// requests split from one subscription fail together
+	sub := mergeSubscriptions(subs, true)
+	trigger := sub.trigger
+	sub.trigger = func(ctx context.Context) error {
+		acquired, err := c.slots.acquire(ctx, len(groups))
+		if err != nil {
+			return err
+		}
+		for _, release := range acquired {
+			releases <- release
+		}
+		return trigger(ctx)
+	}
+	return sub, nil
+}
 
+// subscribe creates a subscription with filters sent in a single request.
+// acquire is called to take a subscription slot before sending the request.
+func (c *Client) subscribe(filters []Filter, o *subscriptionOptions, acquire func(context.Context) (func(), error)) *Subscription {
 	id := uuid.New().String()
-	eventChan := make(chan *Event)
+	eventChan := make(chan *Event, o.bufferSize)
 	eoseChan := make(chan struct{}, 1)
+	errChan := make(chan error, 1)
+	dropped := new(atomic.Int64)
 
 	trigger := func(ctx context.Context) error {
+		release, err := acquire(ctx)
+		if err != nil {
+			return err
+		}
+
 		// register subscription to client
-		c.subMap.Store(id, &subChannelGroup{
+		group := &subChannelGroup{
+			filters:   filters,
 			eventChan: eventChan,
 			eoseChan:  eoseChan,
-		})
+			errChan:   errChan,
+			overflow:  o.overflow,
+			dropped:   dropped,
+			done:      make(chan struct{}),
+			release:   release,
+			backfill:  true,
+		}
+		c.subMap.Store(id, group)
 
 		req := ReqMessage{
 			SubscriptionID: id,
@@ -117,6 +333,7 @@ func (c *Client) Subscribe(ctx context.Context, filters []Filter) (*Subscription
 		if err := c.writeMessage(ctx, &req); err != nil {
 			// unregister subscription from client
 			c.subMap.Delete(id)
+			group.close()
 			return err
 		}
 		return nil
@@ -124,22 +341,80 @@ func (c *Client) Subscribe(ctx context.Context, filters []Filter) (*Subscription
 
 	closer := func(ctx context.Context) error {
 		req := CloseMessage{SubscriptionID: id}
-		if err := c.writeMessage(ctx, &req); err != nil {
-			return err
-		}
+		err := c.writeMessage(ctx, &req)
 
 		// unregister subscription from client
-		c.subMap.Delete(id)
-		return nil
+		if value, ok := c.subMap.LoadAndDelete(id); ok {
+			if group, ok := value.(*subChannelGroup); ok {
+				group.close()
+			}
+		}
+		return err
 	}
 
 	return &Subscription{
 		id:        id,
 		eventChan: eventChan,
 		eoseChan:  eoseChan,
+		errChan:   errChan,
+		dropped:   dropped.Load,
 		trigger:   trigger,
 		closer:    closer,
-	}, nil
+	}
+}
+
+// Count requests the number of events matching any of the filters, as defined in NIP-45.
+func (c *Client) Count(ctx context.Context, filters []Filter) (int64, error) {
+	result, err := c.CountResult(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// CountResult is like Count but returns the whole result,
+// including whether the count is approximate and the HyperLogLog registers.
+// If the relay server refuses the request, it returns a *ClosedError.
+//
+// If the client enforces the limitation of the relay server,
+// the request takes a slot of max_subscriptions until the result arrives.
+// Filters are not split since counts of separate requests cannot be added up,
+// so it returns a *LimitError if there are more filters than max_filters.
+func (c *Client) CountResult(ctx context.Context, filters []Filter) (*CountResult, error) {
+	if len(filters) == 0 {
+		return nil, errors.New("at least one filter is required")
+	}
+	if l := c.limitation; l != nil && l.MaxFilters > 0 && len(filters) > l.MaxFilters {
+		return nil, &LimitError{Limit: "max_filters", Max: l.MaxFilters, Actual: len(filters)}
+	}
+	releases, err := c.slots.acquire(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer releases[0]()
+
+	id := uuid.New().String()
+	resultChan := make(chan *CountResult, 1)
+	errChan := make(chan error, 1)
+
+	c.countMap.Store(id, &countChannelGroup{
+		resultChan: resultChan,
+		errChan:    errChan,
+	})
+	defer c.countMap.Delete(id)
+
+	if err := c.writeMessage(ctx, &CountMessage{SubscriptionID: id, Filters: filters}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("missing count result: %w", ctx.Err())
+	case err := <-errChan:
+		return nil, err
+	case result := <-resultChan:
+		return result, nil
+	}
 }
 
 // Notice returns a channel that receives notice messages from the relay server.
@@ -147,18 +422,134 @@ func (c *Client) Notice() <-chan string {
 	return c.noticeChan
 }
 
+// State returns the current state of the connection to the relay server.
+func (c *Client) State() ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
 // Close closes the client connection.
 func (c *Client) Close() error {
 	c.closeOnce.Do(func() {
-		close(c.done)
+		c.mu.Lock()
+		c.closed = true
+		conn, state := c.conn, c.state
+		c.mu.Unlock()
+
+		if state == ConnectionStateConnected {
+			if err := conn.Close(websocket.StatusNormalClosure, ""); err != nil {
+				c.closeErr = err
+			}
+		}
+		c.cancel()
+		c.setState(ConnectionStateClosed)
+	})
+	return c.closeErr
+}
+
+func (c *Client) setState(state ConnectionState) {
+	c.mu.Lock()
+	if c.state == state || (c.closed && state != ConnectionStateClosed) {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	c.mu.Unlock()
 
-		err := c.conn.Close(websocket.StatusNormalClosure, "")
+	if c.stateHandler != nil {
+		c.stateHandler(state)
+	}
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Client) readLoop() {
+	for {
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+
+		_, b, err := conn.Read(c.ctx)
 		if err != nil {
-			c.closeErr = err
-			return
+			if c.isClosed() {
+				return
+			}
+			c.setState(ConnectionStateDisconnected)
+			if c.backoff == nil || !c.reconnect() {
+				return
+			}
+			continue
+		}
+
+		if err := c.handleMessage(b); err != nil {
+			continue
+		}
+	}
+}
+
+// reconnect re-establishes the connection to the relay server
+// and re-sends requests of active subscriptions.
+// It returns false if the client is closed before reconnection.
+func (c *Client) reconnect() bool {
+	c.setState(ConnectionStateConnecting)
+
+	delay := c.backoff.initial()
+	for {
+		conn, err := dial(c.ctx, c.url)
+		if err == nil {
+			c.mu.Lock()
+			if c.closed {
+				c.mu.Unlock()
+				conn.Close(websocket.StatusNormalClosure, "")
+				return false
+			}
+			c.conn = conn
+			c.auth = newAuthState()
+			c.mu.Unlock()
+
+			c.setState(ConnectionStateConnected)
+			// requests closed with auth-required are re-sent after authentication
+			c.resubscribe()
+			return true
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		delay = c.backoff.next(delay)
+	}
+}
+
+func (c *Client) resubscribe() {
+	c.subMap.Range(func(key, value any) bool {
+		id, ok := key.(string)
+		if !ok {
+			return true
+		}
+		group, ok := value.(*subChannelGroup)
+		if !ok {
+			return true
 		}
+
+		req := ReqMessage{
+			SubscriptionID: id,
+			Filters:        group.resumeFilters(),
+		}
+		if err := c.writeMessage(c.ctx, &req); err != nil {
+			// the connection is lost again
+			return false
+		}
+		return true
 	})
-	return c.closeErr
 }
 
 func (c *Client) writeMessage(ctx context.Context, message json.Marshaler) error {
@@ -166,91 +557,74 @@ func (c *Client) writeMessage(ctx context.Context, message json.Marshaler) error
 	if err != nil {
 		return err
 	}
-	err = c.conn.Write(ctx, websocket.MessageText, body)
-	if err != nil {
+	if err := c.checkMessage(body); err != nil {
 		return err
 	}
-	return nil
-}
 
-func (c *Client) readMessage(ctx context.Context) error {
-	_, b, err := c.conn.Read(ctx)
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	err = conn.Write(ctx, websocket.MessageText, body)
 	if err != nil {
 		return err
 	}
+	return nil
+}
 
-	var message []json.RawMessage
-	if err = json.Unmarshal(b, &message); err != nil {
-		return err
-	}
-	if len(message) == 0 {
-		return errors.New("empty message")
-	}
-	var typ string
-	if err = json.Unmarshal(message[0], &typ); err != nil {
+func (c *Client) handleMessage(b []byte) error {
+	typ, err := ParseMessageType(b)
+	if err != nil {
 		return err
 	}
 
 	switch typ {
-	case string(MessageTypeNotice):
-		if len(message) != 2 {
-			return fmt.Errorf("invalid notice message length: %d", len(message))
-		}
-		var m string
-		if err = json.Unmarshal(message[1], &m); err != nil {
+	case MessageTypeNotice:
+		var m NoticeMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		c.handleNoticeMessage(&NoticeMessage{Message: m})
+		c.handleNoticeMessage(&m)
 		return nil
-	case string(MessageTypeEvent):
-		if len(message) != 3 {
-			return fmt.Errorf("invalid event message length: %d", len(message))
-		}
-		var subID string
-		if err = json.Unmarshal(message[1], &subID); err != nil {
+	case MessageTypeEvent:
+		var m EventMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		var event Event
-		if err = json.Unmarshal(message[2], &event); err != nil {
+		c.handleEventMessage(&m)
+		return nil
+	case MessageTypeEOSE:
+		var m EOSEMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		c.handleEventMessage(&EventMessage{
-			SubscriptionID: subID,
-			Event:          &event,
-		})
+		c.handleEOSEMessage(&m)
 		return nil
-	case string(MessageTypeEOSE):
-		if len(message) != 2 {
-			return fmt.Errorf("invalid EOSE message length: %d", len(message))
-		}
-		var subID string
-		if err = json.Unmarshal(message[1], &subID); err != nil {
+	case MessageTypeOK:
+		var m OKMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		c.handleEOSEMessage(&EOSEMessage{SubscriptionID: subID})
+		c.handleOKMessage(&m)
 		return nil
-	case string(MessageTypeOK):
-		if len(message) != 4 {
-			return fmt.Errorf("invalid OK message length: %d", len(message))
-		}
-		var eventID string
-		if err = json.Unmarshal(message[1], &eventID); err != nil {
+	case MessageTypeClosed:
+		var m ClosedMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		var ok bool
-		if err = json.Unmarshal(message[2], &ok); err != nil {
+		return c.handleClosedMessage(&m)
+	case MessageTypeCount:
+		var m CountMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		var m string
-		if err = json.Unmarshal(message[3], &m); err != nil {
+		return c.handleCountMessage(&m)
+	case MessageTypeAuth:
+		var m AuthMessage
+		if err = m.UnmarshalJSON(b); err != nil {
 			return err
 		}
-		c.handleOKMessage(&OKMessage{
-			EventID: eventID,
-			OK:      ok,
-			Message: m,
-		})
-		return nil
+		return c.handleAuthMessage(&m)
 	}
 
 	return fmt.Errorf("unsupported message type: %s", typ)
@@ -275,11 +649,19 @@ func (c *Client) handleEventMessage(m *EventMessage) error {
 		return errors.New("invalid value in subsciption map")
 	}
 
-	select {
-	case group.eventChan <- m.Event:
-	default:
-		// drop message
+	if c.verifyEvents {
+		if err := m.Event.Verify(); err != nil {
+			if c.invalidEventHandler != nil {
+				c.invalidEventHandler(m.SubscriptionID, m.Event, err)
+			}
+			return fmt.Errorf("invalid event message: %w", err)
+		}
 	}
+	if !group.observe(m.Event) {
+		return nil
+	}
+
+	c.deliver(m.SubscriptionID, group, m.Event)
 	return nil
 }
 
@@ -292,6 +674,7 @@ func (c *Client) handleEOSEMessage(m *EOSEMessage) error {
 	if !ok {
 		return errors.New("invalid value in subsciption map")
 	}
+	group.endBackfill()
 
 	select {
 	case group.eoseChan <- struct{}{}:
@@ -301,6 +684,126 @@ func (c *Client) handleEOSEMessage(m *EOSEMessage) error {
 	return nil
 }
 
+func (c *Client) handleClosedMessage(m *ClosedMessage) error {
+	if reason, _ := ParseReason(m.Message); reason == ReasonAuthRequired && c.authSigner != nil {
+		if value, ok := c.subMap.Load(m.SubscriptionID); ok {
+			group, ok := value.(*subChannelGroup)
+			if !ok {
+				return errors.New("invalid value in subsciption map")
+			}
+			if c.resubscribeAfterAuth(m, group) {
+				return nil
+			}
+		}
+	}
+
+	// unregister subscription from client not to re-send the request after reconnection
+	value, ok := c.subMap.LoadAndDelete(m.SubscriptionID)
+	if !ok {
+		// relay servers refuse count requests with closed messages
+		if value, ok := c.countMap.Load(m.SubscriptionID); ok {
+			group, ok := value.(*countChannelGroup)
+			if !ok {
+				return errors.New("invalid value in count map")
+			}
+			select {
+			case group.errChan <- newClosedError(m):
+			default:
+				// drop message
+			}
+			return nil
+		}
+		return fmt.Errorf("unaddressed closed message: subscription id: %s", m.SubscriptionID)
+	}
+	group, ok := value.(*subChannelGroup)
+	if !ok {
+		return errors.New("invalid value in subsciption map")
+	}
+
+	group.close()
+
+	select {
+	case group.errChan <- newClosedError(m):
+	default:
+		// drop message
+	}
+	return nil
+}
+
+// resubscribeAfterAuth re-sends the request of the subscription closed with auth-required
+// once the client is authenticated on the current connection.
+// It reports false if the client has already been authenticated,
+// in which case authentication does not help.
+// The subscription is closed with the error if the client is not authenticated in time.
+func (c *Client) resubscribeAfterAuth(m *ClosedMessage, group *subChannelGroup) bool {
+	c.mu.Lock()
+	auth := c.auth
+	c.mu.Unlock()
+	select {
+	case <-auth.done:
+		return false
+	default:
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(c.ctx, authTimeout)
+		defer cancel()
+
+		select {
+		case <-auth.done:
+		case <-group.done:
+			return
+		case <-ctx.Done():
+			c.mu.Lock()
+			reconnected := c.auth != auth
+			c.mu.Unlock()
+			if reconnected {
+				// the request has been re-sent on the new connection
+				return
+			}
+			if c.subMap.CompareAndDelete(m.SubscriptionID, group) {
+				group.close()
+				select {
+				case group.errChan <- newClosedError(m):
+				default:
+				}
+			}
+			return
+		}
+
+		if value, ok := c.subMap.Load(m.SubscriptionID); !ok || value != group {
+			return
+		}
+		req := ReqMessage{
+			SubscriptionID: m.SubscriptionID,
+			Filters:        group.resumeFilters(),
+		}
+		_ = c.writeMessage(c.ctx, &req)
+	}()
+	return true
+}
+
+func (c *Client) handleCountMessage(m *CountMessage) error {
+	if m.Result == nil {
+		return errors.New("count message without result")
+	}
+	value, ok := c.countMap.Load(m.SubscriptionID)
+	if !ok {
+		return fmt.Errorf("unaddressed count message: subscription id: %s", m.SubscriptionID)
+	}
+	group, ok := value.(*countChannelGroup)
+	if !ok {
+		return errors.New("invalid value in count map")
+	}
+
+	select {
+	case group.resultChan <- m.Result:
+	default:
+		// drop message
+	}
+	return nil
+}
+
 func (c *Client) handleOKMessage(m *OKMessage) error {
 	value, ok := c.eventMap.Load(m.EventID)
 	if !ok {
@@ -312,10 +815,7 @@ func (c *Client) handleOKMessage(m *OKMessage) error {
 	}
 
 	select {
-	case group.okChan <- &CommandResult{
-		OK:      m.OK,
-		Message: m.Message,
-	}:
+	case group.okChan <- newCommandResult(m):
 	default:
 		// drop message
 	}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -264,3 +266,649 @@ func TestClientSubscribe(t *testing.T) {
 		t.Fatalf("unexpected number of received EOSE message: %d", count)
 	}
 }
+
+func TestClientSubscribeWithEventVerification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ != "REQ" {
+				continue
+			}
+			var subscriptionID string
+			if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+
+			privKey, err := NewPrivateKey()
+			if err != nil {
+				return
+			}
+			valid := &Event{
+				CreatedAt: time.Now().Unix(),
+				Kind:      EventKindTextNote,
+				Tags:      []Tag{},
+				Content:   "valid text note",
+			}
+			if err := valid.Sign(privKey); err != nil {
+				return
+			}
+			forged := *valid
+			forged.Content = "forged text note"
+
+			for _, event := range []*Event{&forged, valid} {
+				if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+					return
+				}
+			}
+			if err := wsjson.Write(ctx, conn, []any{"EOSE", subscriptionID}); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	var invalid atomic.Int32
+	client, err := NewClient(server.URL, WithEventVerification(func(_ string, event *Event, err error) {
+		if event.Content != "forged text note" {
+			t.Errorf("unexpected invalid event: %s", event.Content)
+		}
+		if !errors.Is(err, ErrInvalidID) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		invalid.Add(1)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	sub, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+	go func() {
+		<-sub.EOSE()
+		cancel()
+	}()
+
+	var received atomic.Int32
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		if event.Content != "valid text note" {
+			t.Errorf("unexpected event: %s", event.Content)
+		}
+		received.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := received.Load(); count != 1 {
+		t.Fatalf("unexpected number of received events: %d", count)
+	}
+	if count := invalid.Load(); count != 1 {
+		t.Fatalf("unexpected number of invalid events: %d", count)
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	privKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	var connections atomic.Int32
+	subscriptionIDs := make(chan string, 2)
+	filters := make(chan Filter, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+		n := connections.Add(1)
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ != "REQ" {
+				continue
+			}
+			var subscriptionID string
+			if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+			var filter Filter
+			if err = json.Unmarshal(message[2], &filter); err != nil {
+				return
+			}
+			subscriptionIDs <- subscriptionID
+			filters <- filter
+
+			event := &Event{
+				CreatedAt: createdAt + int64(n),
+				Kind:      EventKindTextNote,
+				Tags:      []Tag{},
+				Content:   "short text note",
+			}
+			if err := event.Sign(privKey); err != nil {
+				return
+			}
+			if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+				return
+			}
+			if err := wsjson.Write(ctx, conn, []any{"EOSE", subscriptionID}); err != nil {
+				return
+			}
+			if n == 1 {
+				// drop the first connection
+				conn.Close(websocket.StatusGoingAway, "")
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	var (
+		mu     sync.Mutex
+		states []ConnectionState
+	)
+	client, err := NewClient(
+		server.URL,
+		WithReconnect(Backoff{Initial: 10 * time.Millisecond}),
+		WithConnectionStateHandler(func(state ConnectionState) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, state)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	sub, err := client.Subscribe(ctx, []Filter{{Kinds: []EventKind{EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var received atomic.Int32
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		if received.Add(1) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := received.Load(); count != 2 {
+		t.Fatalf("unexpected number of received events: %d", count)
+	}
+
+	if first, second := <-subscriptionIDs, <-subscriptionIDs; first != second {
+		t.Errorf("subscription id changed after reconnection: %s, %s", first, second)
+	}
+	if filter := <-filters; filter.Since != 0 {
+		t.Errorf("unexpected since in first request: %d", filter.Since)
+	}
+	if filter := <-filters; filter.Since != createdAt+1 {
+		t.Errorf("unexpected since in resumed request: %d", filter.Since)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.State(); state != ConnectionStateClosed {
+		t.Errorf("unexpected state: %s", state)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []ConnectionState{
+		ConnectionStateDisconnected,
+		ConnectionStateConnecting,
+		ConnectionStateConnected,
+		ConnectionStateClosed,
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("unexpected state transitions: %v", states)
+	}
+}
+
+func TestClientReconnectDuplicates(t *testing.T) {
+	privKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	newEvent := func(content string) *Event {
+		event := &Event{
+			CreatedAt: createdAt,
+			Kind:      EventKindTextNote,
+			Tags:      []Tag{},
+			Content:   content,
+		}
+		if err := event.Sign(privKey); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+	// all events are created at the same time as since of resumed filters
+	first, second, third := newEvent("first"), newEvent("second"), newEvent("third")
+
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+		n := connections.Add(1)
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ != "REQ" {
+				continue
+			}
+			var subscriptionID string
+			if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+
+			events := []*Event{first, second}
+			if n > 1 {
+				// send the events again as a relay server does for the inclusive since
+				events = []*Event{first, second, third}
+			}
+			for _, event := range events {
+				if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+					return
+				}
+			}
+			if n == 1 {
+				// drop the first connection
+				conn.Close(websocket.StatusGoingAway, "")
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithReconnect(Backoff{Initial: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	sub, err := client.Subscribe(ctx, []Filter{{Kinds: []EventKind{EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var contents []string
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		contents = append(contents, event.Content)
+		if event.ID == third.ID {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"first", "second", "third"}; !reflect.DeepEqual(contents, expected) {
+		t.Errorf("unexpected events: %v", contents)
+	}
+}
+
+func TestClientReconnectBeforeEOSE(t *testing.T) {
+	privKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	// stored events newest first
+	var stored []*Event
+	for i, content := range []string{"newer", "older"} {
+		event := &Event{
+			CreatedAt: createdAt - int64(i),
+			Kind:      EventKindTextNote,
+			Tags:      []Tag{},
+			Content:   content,
+		}
+		if err := event.Sign(privKey); err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, event)
+	}
+
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+		n := connections.Add(1)
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ != "REQ" {
+				continue
+			}
+			var subscriptionID string
+			if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+			var filter Filter
+			if err = json.Unmarshal(message[2], &filter); err != nil {
+				return
+			}
+
+			for _, event := range stored {
+				if !filter.Matches(event) {
+					continue
+				}
+				if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+					return
+				}
+				if n == 1 {
+					// drop the first connection between stored events
+					conn.Close(websocket.StatusGoingAway, "")
+					return
+				}
+			}
+			if err := wsjson.Write(ctx, conn, []any{"EOSE", subscriptionID}); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithReconnect(Backoff{Initial: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{Kinds: []EventKind{EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		select {
+		case <-sub.EOSE():
+			// wait for buffered events to be consumed
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		contents []string
+	)
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		contents = append(contents, event.Content)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if expected := []string{"newer", "older"}; !reflect.DeepEqual(contents, expected) {
+		t.Errorf("unexpected events: %v", contents)
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn.Close(websocket.StatusGoingAway, "")
+	}))
+	defer server.Close()
+
+	disconnected := make(chan struct{})
+	client, err := NewClient(server.URL, WithConnectionStateHandler(func(state ConnectionState) {
+		if state == ConnectionStateDisconnected {
+			close(disconnected)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("disconnection is not reported")
+	}
+	if state := client.State(); state != ConnectionStateDisconnected {
+		t.Errorf("unexpected state: %s", state)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSubscribeClosed(t *testing.T) {
+	reqs := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+			if typ != "REQ" {
+				reqs <- typ
+				continue
+			}
+			var subscriptionID string
+			if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+				return
+			}
+			if err := wsjson.Write(ctx, conn, []any{"CLOSED", subscriptionID, "auth-required: we only serve authenticated users"}); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sub, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.Receive(ctx, func(context.Context, *Event) {})
+	var closedErr *ClosedError
+	if !errors.As(err, &closedErr) {
+		t.Fatalf("sub.Receive() returned %v, expected closed error", err)
+	}
+	expected := &ClosedError{
+		SubscriptionID: sub.ID(),
+		Reason:         "auth-required",
+		Message:        "we only serve authenticated users",
+	}
+	if !reflect.DeepEqual(closedErr, expected) {
+		t.Errorf("unexpected closed error: %+v", closedErr)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("sub.Receive() returned after context is done")
+	}
+	if _, ok := client.subMap.Load(sub.ID()); ok {
+		t.Errorf("closed subscription is still registered")
+	}
+
+	// CLOSE is not sent for the subscription closed by the relay server
+	select {
+	case typ := <-reqs:
+		t.Errorf("unexpected message: %s", typ)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// testSigner is a Signer with a private key held in memory.
+type testSigner struct {
+	key PrivateKey
+}
+
+func (s *testSigner) PublicKey(context.Context) (string, error) {
+	return s.key.PublicKey().Hex(), nil
+}
+
+func (s *testSigner) SignEvent(_ context.Context, event *Event) error {
+	return event.SignWithKey(&s.key)
+}
+
+func TestClientSignAndPublish(t *testing.T) {
+	server := newPoolTestServer(nil)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	key, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &testSigner{key: key}
+
+	event := &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := client.SignAndPublish(ctx, signer, event)
+	if err != nil {
+		t.Fatalf("client.SignAndPublish() failed: %s", err)
+	}
+	if !result.OK {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if event.PubKey != key.PublicKey().Hex() {
+		t.Errorf("unexpected public key: %s", event.PubKey)
+	}
+	if err := event.Verify(); err != nil {
+		t.Errorf("event.Verify() failed: %s", err)
+	}
+}
+
+func TestClientWithRejectionErrors(t *testing.T) {
+	server := newAuthTestServer("challenge-string", false)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRejectionErrors())
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	event := &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result, err := client.SignAndPublish(ctx, newTestSigner(t), event)
+	var rejectedErr *RejectedError
+	if !errors.As(err, &rejectedErr) {
+		t.Fatalf("client.SignAndPublish() returned %v, expected rejected error", err)
+	}
+	if rejectedErr.EventID != event.ID || rejectedErr.Reason != ReasonAuthRequired {
+		t.Errorf("unexpected rejected error: %+v", rejectedErr)
+	}
+	if result == nil || !result.IsAuthRequired() {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
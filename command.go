@@ -1,7 +1,109 @@
 package nostr
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Reason is the machine-readable prefix of messages in OK and CLOSED messages from relay servers.
+// Relay servers may use prefixes other than the predefined ones.
+type Reason string
+
+const (
+	ReasonDuplicate    Reason = "duplicate"
+	ReasonPow          Reason = "pow"
+	ReasonBlocked      Reason = "blocked"
+	ReasonRateLimited  Reason = "rate-limited"
+	ReasonInvalid      Reason = "invalid"
+	ReasonError        Reason = "error"
+	ReasonAuthRequired Reason = "auth-required" // NIP-42
+	ReasonRestricted   Reason = "restricted"    // NIP-42
+)
+
+// ParseReason splits a message from relay servers into the machine-readable prefix and the rest.
+// It returns an empty reason if the message does not have a prefix.
+func ParseReason(message string) (Reason, string) {
+	i := strings.Index(message, ":")
+	if i <= 0 || strings.ContainsAny(message[:i], " \t\n") {
+		return "", message
+	}
+	return Reason(message[:i]), strings.TrimSpace(message[i+1:])
+}
+
+// ReasonOf returns the reason of a *RejectedError or a *ClosedError in the chain of err.
+// It returns an empty reason otherwise.
+func ReasonOf(err error) Reason {
+	var rejectedErr *RejectedError
+	if errors.As(err, &rejectedErr) {
+		return rejectedErr.Reason
+	}
+	var closedErr *ClosedError
+	if errors.As(err, &closedErr) {
+		return closedErr.Reason
+	}
+	return ""
+}
+
 // A CommandResult is a result of a command.
 type CommandResult struct {
+	EventID string
 	OK      bool
 	Message string
+	// Reason is the machine-readable prefix of Message.
+	Reason Reason
+}
+
+func newCommandResult(m *OKMessage) *CommandResult {
+	reason, _ := ParseReason(m.Message)
+	return &CommandResult{
+		EventID: m.EventID,
+		OK:      m.OK,
+		Message: m.Message,
+		Reason:  reason,
+	}
+}
+
+// IsDuplicate reports whether the relay server already has the event.
+func (r *CommandResult) IsDuplicate() bool {
+	return r.Reason == ReasonDuplicate
+}
+
+// IsRateLimited reports whether the event is rejected due to rate limiting.
+func (r *CommandResult) IsRateLimited() bool {
+	return r.Reason == ReasonRateLimited
+}
+
+// IsAuthRequired reports whether the event is rejected because the client is not authenticated.
+func (r *CommandResult) IsAuthRequired() bool {
+	return r.Reason == ReasonAuthRequired
+}
+
+// Err returns a *RejectedError if the event is rejected, or nil otherwise.
+// Duplicate events are not considered rejected since the relay server has them.
+func (r *CommandResult) Err() error {
+	if r.OK || r.IsDuplicate() {
+		return nil
+	}
+	_, message := ParseReason(r.Message)
+	return &RejectedError{
+		EventID: r.EventID,
+		Reason:  r.Reason,
+		Message: message,
+	}
+}
+
+// A RejectedError is returned when the relay server rejects an event.
+type RejectedError struct {
+	EventID string
+	Reason  Reason
+	// Message is the human-readable message following the prefix.
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("event rejected by relay server: %s", e.Message)
+	}
+	return fmt.Sprintf("event rejected by relay server: %s: %s", e.Reason, e.Message)
 }
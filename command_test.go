@@ -0,0 +1,86 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseReason(t *testing.T) {
+	for _, tc := range []struct {
+		message string
+		reason  Reason
+		rest    string
+	}{
+		{message: "duplicate: already have this event", reason: ReasonDuplicate, rest: "already have this event"},
+		{message: "rate-limited:slow down", reason: ReasonRateLimited, rest: "slow down"},
+		{message: "auth-required: ", reason: ReasonAuthRequired, rest: ""},
+		{message: "mute: no one was listening", reason: "mute", rest: "no one was listening"},
+		{message: "", reason: "", rest: ""},
+		{message: "no prefix", reason: "", rest: "no prefix"},
+		{message: "human readable: with colon", reason: "", rest: "human readable: with colon"},
+		{message: ": empty prefix", reason: "", rest: ": empty prefix"},
+	} {
+		reason, rest := ParseReason(tc.message)
+		if reason != tc.reason || rest != tc.rest {
+			t.Errorf("ParseReason(%q) returned (%q, %q), expected (%q, %q)", tc.message, reason, rest, tc.reason, tc.rest)
+		}
+	}
+}
+
+func TestCommandResultErr(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		message  OKMessage
+		expected error
+	}{
+		{
+			name:    "accepted",
+			message: OKMessage{EventID: "f926f5", OK: true},
+		},
+		{
+			name:    "duplicate",
+			message: OKMessage{EventID: "f926f5", OK: false, Message: "duplicate: already have this event"},
+		},
+		{
+			name:     "rejected",
+			message:  OKMessage{EventID: "f926f5", OK: false, Message: "pow: difficulty 25>=24"},
+			expected: &RejectedError{EventID: "f926f5", Reason: ReasonPow, Message: "difficulty 25>=24"},
+		},
+		{
+			name:     "rejected without prefix",
+			message:  OKMessage{EventID: "f926f5", OK: false, Message: "not accepted"},
+			expected: &RejectedError{EventID: "f926f5", Message: "not accepted"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newCommandResult(&tc.message).Err()
+			if tc.expected == nil {
+				if err != nil {
+					t.Errorf("result.Err() returned %v", err)
+				}
+				return
+			}
+			var rejectedErr *RejectedError
+			if !errors.As(err, &rejectedErr) || *rejectedErr != *tc.expected.(*RejectedError) {
+				t.Errorf("result.Err() returned %v, expected %v", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReasonOf(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected Reason
+	}{
+		{err: &RejectedError{Reason: ReasonRateLimited}, expected: ReasonRateLimited},
+		{err: fmt.Errorf("wrapped: %w", &ClosedError{Reason: ReasonAuthRequired}), expected: ReasonAuthRequired},
+		{err: errors.New("other error"), expected: ""},
+		{err: nil, expected: ""},
+	} {
+		if reason := ReasonOf(tc.err); reason != tc.expected {
+			t.Errorf("ReasonOf(%v) returned %q, expected %q", tc.err, reason, tc.expected)
+		}
+	}
+}
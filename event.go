@@ -3,37 +3,76 @@ package nostr
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"unicode/utf8"
 
-	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 )
 
+var (
+	// ErrInvalidID is returned when the ID of an event does not match its content.
+	ErrInvalidID = errors.New("invalid event id")
+	// ErrInvalidSignature is returned when the signature of an event is not valid.
+	ErrInvalidSignature = errors.New("invalid event signature")
+)
+
 // EventKind is the kind of an event.
 type EventKind int64
 
 const (
-	EventKindSetMetadata             EventKind = 0    // NIP-01
-	EventKindTextNote                EventKind = 1    // NIP-01
-	EventKindRecommendServer         EventKind = 2    // NIP-01
-	EventKindContacts                EventKind = 3    // NIP-02
-	EventKindEncryptedDirectMessages EventKind = 4    // NIP-04
-	EventKindEventDeletion           EventKind = 5    // NIP-09
-	EventKindReposts                 EventKind = 6    // NIP-18
-	EventKindReaction                EventKind = 7    // NIP-25
-	EventKindBadgeAward              EventKind = 8    // NIP-58
-	EventKindChannelCreation         EventKind = 40   // NIP-28
-	EventKindChannelMetadata         EventKind = 41   // NIP-28
-	EventKindChannelMessage          EventKind = 42   // NIP-28
-	EventKindChannelHideMessage      EventKind = 43   // NIP-28
-	EventKindChannelMuteUser         EventKind = 44   // NIP-28
-	EventKindFileMetadata            EventKind = 1063 // NIP-94
-	EventKindReporting               EventKind = 1984 // NIP-56
-	EventKindZapRequest              EventKind = 9734 // NIP-57
-	EventKindZap                     EventKind = 9735 // NIP-57
+	EventKindSetMetadata             EventKind = 0     // NIP-01
+	EventKindTextNote                EventKind = 1     // NIP-01
+	EventKindRecommendServer         EventKind = 2     // NIP-01
+	EventKindContacts                EventKind = 3     // NIP-02
+	EventKindEncryptedDirectMessages EventKind = 4     // NIP-04
+	EventKindEventDeletion           EventKind = 5     // NIP-09
+	EventKindReposts                 EventKind = 6     // NIP-18
+	EventKindReaction                EventKind = 7     // NIP-25
+	EventKindBadgeAward              EventKind = 8     // NIP-58
+	EventKindSeal                    EventKind = 13    // NIP-59
+	EventKindPrivateDirectMessage    EventKind = 14    // NIP-17
+	EventKindChannelCreation         EventKind = 40    // NIP-28
+	EventKindChannelMetadata         EventKind = 41    // NIP-28
+	EventKindChannelMessage          EventKind = 42    // NIP-28
+	EventKindChannelHideMessage      EventKind = 43    // NIP-28
+	EventKindChannelMuteUser         EventKind = 44    // NIP-28
+	EventKindGiftWrap                EventKind = 1059  // NIP-59
+	EventKindFileMetadata            EventKind = 1063  // NIP-94
+	EventKindReporting               EventKind = 1984  // NIP-56
+	EventKindZapRequest              EventKind = 9734  // NIP-57
+	EventKindZap                     EventKind = 9735  // NIP-57
+	EventKindClientAuthentication    EventKind = 22242 // NIP-42
+	EventKindNostrConnect            EventKind = 24133 // NIP-46
 )
 
+// IsRegular reports whether events of the kind are regular events,
+// which are expected to be stored by relays.
+func (k EventKind) IsRegular() bool {
+	return !k.IsReplaceable() && !k.IsEphemeral() && !k.IsAddressable()
+}
+
+// IsReplaceable reports whether events of the kind are replaceable events.
+// Only the latest event for each combination of pubkey and kind is expected to be stored by relays.
+func (k EventKind) IsReplaceable() bool {
+	return k == EventKindSetMetadata || k == EventKindContacts || (10000 <= k && k < 20000)
+}
+
+// IsEphemeral reports whether events of the kind are ephemeral events,
+// which are not expected to be stored by relays.
+func (k EventKind) IsEphemeral() bool {
+	return 20000 <= k && k < 30000
+}
+
+// IsAddressable reports whether events of the kind are addressable events
+// (parameterized replaceable events).
+// Only the latest event for each combination of pubkey, kind and "d" tag value
+// is expected to be stored by relays.
+func (k EventKind) IsAddressable() bool {
+	return 30000 <= k && k < 40000
+}
+
 // Tag is a tag of an event.
 type Tag []string
 
@@ -48,23 +87,54 @@ type Event struct {
 	Sig       string    `json:"sig"`
 }
 
-// Sing signs the event with the given private key.
+// Address returns the coordinate of a replaceable or addressable event
+// in the form of "<kind>:<pubkey>:<d tag value>".
+// The "d" tag value is empty for replaceable events.
+// It returns an empty string for other events.
+func (e *Event) Address() string {
+	switch {
+	case e.Kind.IsReplaceable():
+		return fmt.Sprintf("%d:%s:", e.Kind, e.PubKey)
+	case e.Kind.IsAddressable():
+		return fmt.Sprintf("%d:%s:%s", e.Kind, e.PubKey, e.identifier())
+	}
+	return ""
+}
+
+// identifier returns the value of the first "d" tag.
+func (e *Event) identifier() string {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == "d" {
+			return tag[1]
+		}
+	}
+	return ""
+}
+
+// Sign signs the event with the given private key in hex.
 // It sets the ID, PubKey, and Sig fields.
 func (e *Event) Sign(privKey string) error {
-	s, err := hex.DecodeString(privKey)
+	key, err := ParsePrivateKey(privKey)
 	if err != nil {
-		return fmt.Errorf("invalid private key: %w", err)
+		return err
 	}
-	sk, pk := btcec.PrivKeyFromBytes(s)
+	defer key.Zero()
+	return e.SignWithKey(&key)
+}
+
+// SignWithKey signs the event with the given private key.
+// It sets the ID, PubKey, and Sig fields.
+func (e *Event) SignWithKey(key *PrivateKey) error {
+	sk := key.key()
+	defer sk.Zero()
 
 	// public key
-	e.PubKey = hex.EncodeToString(pk.SerializeCompressed()[1:])
+	e.PubKey = hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey()))
 
-	serial, err := e.serialize()
+	serialHash, err := e.hash()
 	if err != nil {
 		return fmt.Errorf("invalid event: %w", err)
 	}
-	serialHash := sha256.Sum256(serial)
 
 	// id
 	e.ID = hex.EncodeToString(serialHash[:])
@@ -78,17 +148,128 @@ func (e *Event) Sign(privKey string) error {
 	return nil
 }
 
-func (e *Event) serialize() ([]byte, error) {
-	b, err := json.Marshal([]any{
-		0,
-		e.PubKey,
-		e.CreatedAt,
-		e.Kind,
-		e.Tags,
-		e.Content,
-	})
+// ComputeID returns the ID of the event computed from its serialization.
+// It does not modify the event.
+func (e *Event) ComputeID() (string, error) {
+	serialHash, err := e.hash()
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("invalid event: %w", err)
+	}
+	return hex.EncodeToString(serialHash[:]), nil
+}
+
+// CheckID reports whether the ID field matches the ID computed from the event.
+func (e *Event) CheckID() bool {
+	id, err := e.ComputeID()
+	if err != nil {
+		return false
+	}
+	return id == e.ID
+}
+
+// Verify checks that the ID field matches the event
+// and that Sig is a valid signature of the ID by PubKey.
+// It returns nil if the event is authentic.
+func (e *Event) Verify() error {
+	serialHash, err := e.hash()
+	if err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+	if hex.EncodeToString(serialHash[:]) != e.ID {
+		return ErrInvalidID
+	}
+
+	p, err := hex.DecodeString(e.PubKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	pk, err := schnorr.ParsePubKey(p)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	s, err := hex.DecodeString(e.Sig)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	sig, err := schnorr.ParseSignature(s)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !sig.Verify(serialHash[:], pk) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (e *Event) hash() ([32]byte, error) {
+	serial, err := e.serialize()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(serial), nil
+}
+
+func (e *Event) serialize() ([]byte, error) {
+	buf := make([]byte, 0, 128+len(e.Content))
+	buf = append(buf, "[0,"...)
+	buf = appendString(buf, e.PubKey)
+	buf = append(buf, ',')
+	buf = strconv.AppendInt(buf, e.CreatedAt, 10)
+	buf = append(buf, ',')
+	buf = strconv.AppendInt(buf, int64(e.Kind), 10)
+	buf = append(buf, ",["...)
+	for i, tag := range e.Tags {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '[')
+		for j, v := range tag {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendString(buf, v)
+		}
+		buf = append(buf, ']')
+	}
+	buf = append(buf, "],"...)
+	buf = appendString(buf, e.Content)
+	buf = append(buf, ']')
+	return buf, nil
+}
+
+// appendString appends s to buf as a JSON string escaped as required by NIP-01.
+// Only line breaks, double quotes, backslashes, carriage returns, tabs, backspaces and form feeds
+// are escaped with a backslash, other control characters are escaped as \u00XX,
+// and all other characters such as <, > and U+2028 are written as is.
+// Invalid UTF-8 is replaced with U+FFFD as encoding/json does.
+func appendString(buf []byte, s string) []byte {
+	const hexDigits = "0123456789abcdef"
+
+	buf = append(buf, '"')
+	for _, r := range s {
+		switch r {
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '"':
+			buf = append(buf, '\\', '"')
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\b':
+			buf = append(buf, '\\', 'b')
+		case '\f':
+			buf = append(buf, '\\', 'f')
+		default:
+			if r < 0x20 {
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xf])
+				continue
+			}
+			buf = utf8.AppendRune(buf, r)
+		}
 	}
-	return b, nil
+	return append(buf, '"')
 }
@@ -1,6 +1,10 @@
 package nostr
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -35,3 +39,194 @@ func TestEventSign(t *testing.T) {
 		t.Errorf("event.Sig is %s, expected %s", event.Sig, sig)
 	}
 }
+
+func TestEventVerify(t *testing.T) {
+	newEvent := func() *Event {
+		return &Event{
+			ID:        "f926f58579b974014c091f4d945e8e3de7f3f87bbc4a0b6a49f2b3d68be2c89d",
+			PubKey:    "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e",
+			CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			Kind:      EventKindTextNote,
+			Tags:      []Tag{},
+			Content:   "short text note",
+			Sig:       "7903b45c7863f053bb1e84e6308c0de6f2dd212a9496b2391c83859fec17a3f28427ce74e59deef34ff5c418d871601eb4b8c7a81390f4a3ccb08ba4bce55710",
+		}
+	}
+
+	t.Run("valid event", func(t *testing.T) {
+		event := newEvent()
+		if !event.CheckID() {
+			t.Error("event.CheckID() returned false")
+		}
+		if err := event.Verify(); err != nil {
+			t.Errorf("event.Verify() failed: %s", err)
+		}
+	})
+
+	t.Run("modified content", func(t *testing.T) {
+		event := newEvent()
+		event.Content = "modified text note"
+		if event.CheckID() {
+			t.Error("event.CheckID() returned true")
+		}
+		if err := event.Verify(); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("event.Verify() returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("modified signature", func(t *testing.T) {
+		event := newEvent()
+		event.Sig = "8903b45c7863f053bb1e84e6308c0de6f2dd212a9496b2391c83859fec17a3f28427ce74e59deef34ff5c418d871601eb4b8c7a81390f4a3ccb08ba4bce55710"
+		if !event.CheckID() {
+			t.Error("event.CheckID() returned false")
+		}
+		if err := event.Verify(); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("event.Verify() returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("signed by other key", func(t *testing.T) {
+		privKey, err := NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := newEvent()
+		if err := other.Sign(privKey); err != nil {
+			t.Fatalf("event.Sign() failed: %s", err)
+		}
+
+		event := newEvent()
+		event.Sig = other.Sig
+		if err := event.Verify(); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("event.Verify() returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("html characters", func(t *testing.T) {
+		privKey, err := NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		event := newEvent()
+		event.Content = "<b>bold</b> & more"
+		if err := event.Sign(privKey); err != nil {
+			t.Fatalf("event.Sign() failed: %s", err)
+		}
+
+		serial, err := event.serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(serial), event.Content) {
+			t.Errorf("content is escaped in serialization: %s", serial)
+		}
+		if err := event.Verify(); err != nil {
+			t.Errorf("event.Verify() failed: %s", err)
+		}
+	})
+}
+
+func TestEventSerialize(t *testing.T) {
+	pubKey := "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+
+	for _, tc := range []struct {
+		name     string
+		tags     []Tag
+		content  string
+		expected string
+	}{
+		{"plain", nil, "hello", `[0,"` + pubKey + `",1672531200,1,[],"hello"]`},
+		{"escaped characters", nil, "a\nb\"c\\d\re\tf\bg\fh", `[0,"` + pubKey + `",1672531200,1,[],"a\nb\"c\\d\re\tf\bg\fh"]`},
+		{"other control characters", nil, "\x00\x1f\x7f", `[0,"` + pubKey + `",1672531200,1,[],"\u0000\u001f` + "\x7f" + `"]`},
+		{"html characters", nil, "<b>&</b>", `[0,"` + pubKey + `",1672531200,1,[],"<b>&</b>"]`},
+		{"line and paragraph separators", nil, "a\u2028b\u2029c", `[0,"` + pubKey + `",1672531200,1,[],"a` + "\u2028" + `b` + "\u2029" + `c"]`},
+		{"non-ascii", nil, "こんにちは 🤙", `[0,"` + pubKey + `",1672531200,1,[],"こんにちは 🤙"]`},
+		{"tags", []Tag{{"e", "id"}, {"t", "a\u2028\"b"}, {}}, "", `[0,"` + pubKey + `",1672531200,1,[["e","id"],["t","a` + "\u2028" + `\"b"],[]],""]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &Event{
+				PubKey:    pubKey,
+				CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+				Kind:      EventKindTextNote,
+				Tags:      tc.tags,
+				Content:   tc.content,
+			}
+
+			serial, err := event.serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(serial) != tc.expected {
+				t.Errorf("event.serialize() returned %s, expected %s", serial, tc.expected)
+			}
+
+			hash := sha256.Sum256([]byte(tc.expected))
+			id, err := event.ComputeID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := hex.EncodeToString(hash[:]); id != expected {
+				t.Errorf("event.ComputeID() returned %s, expected %s", id, expected)
+			}
+		})
+	}
+}
+
+func TestEventKindClassification(t *testing.T) {
+	for _, tc := range []struct {
+		kind        EventKind
+		regular     bool
+		replaceable bool
+		ephemeral   bool
+		addressable bool
+	}{
+		{EventKindSetMetadata, false, true, false, false},
+		{EventKindTextNote, true, false, false, false},
+		{EventKindContacts, false, true, false, false},
+		{EventKindEncryptedDirectMessages, true, false, false, false},
+		{EventKindZap, true, false, false, false},
+		{9999, true, false, false, false},
+		{10000, false, true, false, false},
+		{19999, false, true, false, false},
+		{20000, false, false, true, false},
+		{29999, false, false, true, false},
+		{30000, false, false, false, true},
+		{39999, false, false, false, true},
+		{40000, true, false, false, false},
+	} {
+		if got := tc.kind.IsRegular(); got != tc.regular {
+			t.Errorf("EventKind(%d).IsRegular() returned %t", tc.kind, got)
+		}
+		if got := tc.kind.IsReplaceable(); got != tc.replaceable {
+			t.Errorf("EventKind(%d).IsReplaceable() returned %t", tc.kind, got)
+		}
+		if got := tc.kind.IsEphemeral(); got != tc.ephemeral {
+			t.Errorf("EventKind(%d).IsEphemeral() returned %t", tc.kind, got)
+		}
+		if got := tc.kind.IsAddressable(); got != tc.addressable {
+			t.Errorf("EventKind(%d).IsAddressable() returned %t", tc.kind, got)
+		}
+	}
+}
+
+func TestEventAddress(t *testing.T) {
+	pubKey := "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+
+	for _, tc := range []struct {
+		name     string
+		event    *Event
+		expected string
+	}{
+		{"regular", &Event{PubKey: pubKey, Kind: EventKindTextNote}, ""},
+		{"ephemeral", &Event{PubKey: pubKey, Kind: 20001}, ""},
+		{"replaceable", &Event{PubKey: pubKey, Kind: EventKindSetMetadata}, "0:" + pubKey + ":"},
+		{"addressable", &Event{PubKey: pubKey, Kind: 30023, Tags: []Tag{{"t", "nostr"}, {"d", "article"}, {"d", "other"}}}, "30023:" + pubKey + ":article"},
+		{"addressable without d tag", &Event{PubKey: pubKey, Kind: 30023, Tags: []Tag{{"d"}}}, "30023:" + pubKey + ":"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.event.Address(); got != tc.expected {
+				t.Errorf("event.Address() returned %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -1,13 +1,195 @@
 package nostr
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 // A Filter is a filter for subscription.
 type Filter struct {
 	IDs     []string    `json:"ids,omitempty"`
 	Kinds   []EventKind `json:"kinds,omitempty"`
 	Authors []string    `json:"authors,omitempty"`
-	Tags    []Tag       `json:"-,omitempty"`
-	Since   int64       `json:"since,omitempty"`
-	Until   int64       `json:"until,omitempty"`
-	Limit   int         `json:"limit,omitempty"`
-	Search  string      `json:"search,omitempty"`
+	// Tags are tag queries such as {"e", "<event id>", ...}.
+	// The first element of each tag is the tag name and the rest are the values to match.
+	// They are serialized as "#<tag name>" fields,
+	// so queries with the same name are merged and match events with any of their values.
+	Tags   []Tag  `json:"-"`
+	Since  int64  `json:"since,omitempty"`
+	Until  int64  `json:"until,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Search string `json:"search,omitempty"`
+}
+
+// filter is an alias of Filter to use default JSON encoding.
+type filter Filter
+
+func (f Filter) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(filter(f))
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Tags) == 0 {
+		return b, nil
+	}
+
+	for _, tag := range f.Tags {
+		if len(tag) == 0 {
+			return nil, errors.New("empty tag in filter")
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(b[:len(b)-1]) // trim closing brace
+	for i, query := range f.TagQueries() {
+		if i > 0 || len(b) > 2 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal("#" + query[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal([]string(query[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var v filter
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if strings.HasPrefix(key, "#") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var values []string
+		if err := json.Unmarshal(fields[key], &values); err != nil {
+			return fmt.Errorf("invalid tag query %s: %w", key, err)
+		}
+		v.Tags = append(v.Tags, append(Tag{key[1:]}, values...))
+	}
+
+	*f = Filter(v)
+	return nil
+}
+
+// TagQueries returns the tag queries with the values of queries of the same name merged,
+// in the order of the first query of each name, as they are sent to relay servers.
+// An event matches a merged query if it has a tag with any of the values.
+// Empty tags are ignored.
+func (f *Filter) TagQueries() []Tag {
+	var queries []Tag
+	index := map[string]int{}
+	for _, tag := range f.Tags {
+		if len(tag) == 0 {
+			continue
+		}
+		i, ok := index[tag[0]]
+		if !ok {
+			i = len(queries)
+			index[tag[0]] = i
+			queries = append(queries, Tag{tag[0]})
+		}
+		queries[i] = append(queries[i], tag[1:]...)
+	}
+	return queries
+}
+
+// Matches reports whether the event satisfies the filter.
+// Entries of IDs and Authors match events whose ID or public key starts with them.
+// Tag queries of the same name are merged as in TagQueries.
+// Limit and Search are not taken into account.
+func (f *Filter) Matches(event *Event) bool {
+	if event == nil {
+		return false
+	}
+	if len(f.IDs) > 0 && !matchPrefix(f.IDs, event.ID) {
+		return false
+	}
+	if len(f.Authors) > 0 && !matchPrefix(f.Authors, event.PubKey) {
+		return false
+	}
+	if len(f.Kinds) > 0 && !containsKind(f.Kinds, event.Kind) {
+		return false
+	}
+	if f.Since != 0 && event.CreatedAt < f.Since {
+		return false
+	}
+	if f.Until != 0 && event.CreatedAt > f.Until {
+		return false
+	}
+	for _, query := range f.TagQueries() {
+		if !matchTag(event.Tags, query[0], query[1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filters is a list of filters.
+// An event matches the list if it matches any of the filters.
+type Filters []Filter
+
+// Match reports whether the event satisfies any of the filters.
+func (fs Filters) Match(event *Event) bool {
+	for i := range fs {
+		if fs[i].Matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPrefix(prefixes []string, s string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKind(kinds []EventKind, kind EventKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTag(tags []Tag, name string, values []string) bool {
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[0] != name {
+			continue
+		}
+		for _, value := range values {
+			if tag[1] == value {
+				return true
+			}
+		}
+	}
+	return false
 }
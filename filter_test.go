@@ -0,0 +1,215 @@
+package nostr
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{
+			name:     "empty",
+			filter:   Filter{},
+			expected: `{}`,
+		},
+		{
+			name: "without tags",
+			filter: Filter{
+				Kinds: []EventKind{EventKindTextNote},
+				Limit: 10,
+			},
+			expected: `{"kinds":[1],"limit":10}`,
+		},
+		{
+			name: "only tags",
+			filter: Filter{
+				Tags: []Tag{{"t", "nostr"}},
+			},
+			expected: `{"#t":["nostr"]}`,
+		},
+		{
+			name: "with tags",
+			filter: Filter{
+				Kinds: []EventKind{EventKindTextNote},
+				Tags: []Tag{
+					{"e", "f926f58579b974014c091f4d945e8e3de7f3f87bbc4a0b6a49f2b3d68be2c89d"},
+					{"p", "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"},
+					{"e", "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"},
+				},
+				Since: 1672531200,
+			},
+			expected: `{"kinds":[1],"since":1672531200,` +
+				`"#e":["f926f58579b974014c091f4d945e8e3de7f3f87bbc4a0b6a49f2b3d68be2c89d","a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"],` +
+				`"#p":["7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.filter)
+			if err != nil {
+				t.Fatalf("json.Marshal() failed: %s", err)
+			}
+			if string(b) != tc.expected {
+				t.Errorf("json.Marshal() failed: expected %s, got %s", tc.expected, string(b))
+			}
+		})
+	}
+
+	t.Run("empty tag", func(t *testing.T) {
+		if _, err := json.Marshal(Filter{Tags: []Tag{{}}}); err == nil {
+			t.Error("json.Marshal() succeeded unexpectedly")
+		}
+	})
+}
+
+func TestFilterUnmarshalJSON(t *testing.T) {
+	b := `{"ids":["f926f5"],"kinds":[1,7],"#t":["nostr","go"],"#e":["a0b6a4"],"until":1672531200,"search":"text"}`
+	expected := Filter{
+		IDs:   []string{"f926f5"},
+		Kinds: []EventKind{EventKindTextNote, EventKindReaction},
+		Tags: []Tag{
+			{"e", "a0b6a4"},
+			{"t", "nostr", "go"},
+		},
+		Until:  1672531200,
+		Search: "text",
+	}
+
+	var filter Filter
+	if err := json.Unmarshal([]byte(b), &filter); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if !reflect.DeepEqual(filter, expected) {
+		t.Errorf("json.Unmarshal() failed: expected %+v, got %+v", expected, filter)
+	}
+
+	t.Run("invalid tag query", func(t *testing.T) {
+		var filter Filter
+		if err := json.Unmarshal([]byte(`{"#e":"a0b6a4"}`), &filter); err == nil {
+			t.Error("json.Unmarshal() succeeded unexpectedly")
+		}
+	})
+}
+
+func TestFilterMatches(t *testing.T) {
+	event := &Event{
+		ID:        "f926f58579b974014c091f4d945e8e3de7f3f87bbc4a0b6a49f2b3d68be2c89d",
+		PubKey:    "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e",
+		CreatedAt: 1672531200,
+		Kind:      EventKindTextNote,
+		Tags: []Tag{
+			{"e", "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4", "wss://relay.example.com"},
+			{"t", "nostr"},
+			{"r"},
+		},
+		Content: "short text note",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{"empty", Filter{}, true},
+		{"id", Filter{IDs: []string{event.ID}}, true},
+		{"id prefix", Filter{IDs: []string{"0000", "f926f5"}}, true},
+		{"other id", Filter{IDs: []string{"a0b6a4"}}, false},
+		{"author", Filter{Authors: []string{event.PubKey}}, true},
+		{"author prefix", Filter{Authors: []string{"7e7e9c"}}, true},
+		{"other author", Filter{Authors: []string{"f926f5"}}, false},
+		{"kind", Filter{Kinds: []EventKind{EventKindSetMetadata, EventKindTextNote}}, true},
+		{"other kind", Filter{Kinds: []EventKind{EventKindReaction}}, false},
+		{"since", Filter{Since: 1672531200}, true},
+		{"later since", Filter{Since: 1672531201}, false},
+		{"until", Filter{Until: 1672531200}, true},
+		{"earlier until", Filter{Until: 1672531199}, false},
+		{"tag", Filter{Tags: []Tag{{"e", "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"}}}, true},
+		{"one of tag values", Filter{Tags: []Tag{{"t", "bitcoin", "nostr"}}}, true},
+		{"all tags", Filter{Tags: []Tag{{"t", "nostr"}, {"e", "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"}}}, true},
+		{"missing tag", Filter{Tags: []Tag{{"t", "nostr"}, {"p", event.PubKey}}}, false},
+		{"one of tags of the same name", Filter{Tags: []Tag{{"t", "bitcoin"}, {"t", "nostr"}}}, true},
+		{"tag prefix", Filter{Tags: []Tag{{"e", "a0b6a4"}}}, false},
+		{"tag without values", Filter{Tags: []Tag{{"r"}}}, false},
+		{"search is ignored", Filter{Search: "unrelated"}, true},
+		{"limit is ignored", Filter{Limit: 1}, true},
+		{
+			"all conditions",
+			Filter{
+				IDs:     []string{event.ID},
+				Kinds:   []EventKind{EventKindTextNote},
+				Authors: []string{event.PubKey},
+				Tags:    []Tag{{"t", "nostr"}},
+				Since:   1672531100,
+				Until:   1672531300,
+			},
+			true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.Matches(event); got != tc.expected {
+				t.Errorf("filter.Matches() returned %t, expected %t", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFilterMatchesJSON(t *testing.T) {
+	events := []*Event{
+		{Kind: EventKindTextNote, Tags: []Tag{{"t", "nostr"}}},
+		{Kind: EventKindTextNote, Tags: []Tag{{"t", "go"}, {"p", "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"}}},
+		{Kind: EventKindTextNote, Tags: []Tag{{"p", "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"}}},
+		{Kind: EventKindTextNote, Tags: []Tag{}},
+	}
+
+	// a filter matches the same events after being sent to relay servers
+	for _, f := range []Filter{
+		{Tags: []Tag{{"t", "nostr"}, {"t", "go"}}},
+		{Tags: []Tag{{"t", "nostr"}, {"p", "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"}, {"t", "go"}}},
+		{Tags: []Tag{{"t"}, {"t", "go"}}},
+	} {
+		b, err := json.Marshal(f)
+		if err != nil {
+			t.Fatalf("json.Marshal() failed: %s", err)
+		}
+		var sent Filter
+		if err := json.Unmarshal(b, &sent); err != nil {
+			t.Fatalf("json.Unmarshal() failed: %s", err)
+		}
+		for i, event := range events {
+			if f.Matches(event) != sent.Matches(event) {
+				t.Errorf("filter %s matches event %d differently after encoding", b, i)
+			}
+		}
+	}
+}
+
+func TestFiltersMatch(t *testing.T) {
+	event := &Event{
+		ID:        "f926f58579b974014c091f4d945e8e3de7f3f87bbc4a0b6a49f2b3d68be2c89d",
+		PubKey:    "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e",
+		CreatedAt: 1672531200,
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		filters  Filters
+		expected bool
+	}{
+		{"no filters", Filters{}, false},
+		{"any filter", Filters{{Kinds: []EventKind{EventKindReaction}}, {Authors: []string{event.PubKey}}}, true},
+		{"no matching filter", Filters{{Kinds: []EventKind{EventKindReaction}}, {Since: 1672531201}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filters.Match(event); got != tc.expected {
+				t.Errorf("filters.Match() returned %t, expected %t", got, tc.expected)
+			}
+		})
+	}
+}
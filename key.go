@@ -1,28 +1,181 @@
 package nostr
 
 import (
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 )
 
+// A PrivateKey is a secp256k1 private key.
+// Its methods take pointer receivers not to copy the secret,
+// but zeroing is best-effort: Zero clears only the receiver
+// and not copies made by assigning or passing the key by value,
+// and Hex and MarshalText return copies that cannot be cleared.
+type PrivateKey [32]byte
+
+// A PublicKey is a secp256k1 public key in the x-only form defined in BIP-340.
+type PublicKey [32]byte
+
+// GeneratePrivateKey generates a new private key.
+func GeneratePrivateKey() (PrivateKey, error) {
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	defer key.Zero()
+
+	var k PrivateKey
+	key.Key.PutBytes((*[32]byte)(&k))
+	return k, nil
+}
+
+// ParsePrivateKey parses a private key in hex.
+// It returns an error unless the key is 32 bytes and in the range of the curve order.
+func ParsePrivateKey(s string) (PrivateKey, error) {
+	var k PrivateKey
+	if err := k.UnmarshalText([]byte(s)); err != nil {
+		return PrivateKey{}, err
+	}
+	return k, nil
+}
+
+// PublicKey returns the public key that corresponds to the private key.
+func (k *PrivateKey) PublicKey() PublicKey {
+	sk := k.key()
+	defer sk.Zero()
+
+	var p PublicKey
+	copy(p[:], schnorr.SerializePubKey(sk.PubKey()))
+	return p
+}
+
+// SharedSecret returns the x coordinate of the ECDH shared point of k and p.
+func (k *PrivateKey) SharedSecret(p PublicKey) ([]byte, error) {
+	pk, err := schnorr.ParsePubKey(p[:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	sk := k.key()
+	defer sk.Zero()
+	return btcec.GenerateSharedSecret(sk, pk), nil
+}
+
+// Hex returns the private key in hex.
+// The returned string cannot be zeroed.
+func (k *PrivateKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+// Equal reports whether k and other are the same key in constant time.
+func (k *PrivateKey) Equal(other *PrivateKey) bool {
+	return subtle.ConstantTimeCompare(k[:], other[:]) == 1
+}
+
+// Zero overwrites the key with zeros.
+// It should be called when the key is no longer needed.
+func (k *PrivateKey) Zero() {
+	for i := range k {
+		k[i] = 0
+	}
+}
+
+// MarshalText encodes the key in hex, with a value receiver so that values are marshaled too.
+func (k PrivateKey) MarshalText() ([]byte, error) {
+	b := make([]byte, hex.EncodedLen(len(k)))
+	hex.Encode(b, k[:])
+	return b, nil
+}
+
+func (k *PrivateKey) UnmarshalText(b []byte) error {
+	var v PrivateKey
+	if err := decodeKey(v[:], b); err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	var scalar btcec.ModNScalar
+	if overflow := scalar.SetBytes((*[32]byte)(&v)); overflow != 0 || scalar.IsZero() {
+		return errors.New("invalid private key: out of range")
+	}
+	*k = v
+	return nil
+}
+
+// key returns the key for use with btcec.
+func (k *PrivateKey) key() *btcec.PrivateKey {
+	sk, _ := btcec.PrivKeyFromBytes(k[:])
+	return sk
+}
+
+// ParsePublicKey parses a public key in hex.
+// It returns an error unless the key is 32 bytes and a valid point on the curve.
+func ParsePublicKey(s string) (PublicKey, error) {
+	var p PublicKey
+	if err := p.UnmarshalText([]byte(s)); err != nil {
+		return PublicKey{}, err
+	}
+	return p, nil
+}
+
+// Hex returns the public key in hex.
+func (p PublicKey) Hex() string {
+	return hex.EncodeToString(p[:])
+}
+
+func (p PublicKey) String() string {
+	return p.Hex()
+}
+
+// Equal reports whether p and other are the same key in constant time.
+func (p PublicKey) Equal(other PublicKey) bool {
+	return subtle.ConstantTimeCompare(p[:], other[:]) == 1
+}
+
+func (p PublicKey) MarshalText() ([]byte, error) {
+	b := make([]byte, hex.EncodedLen(len(p)))
+	hex.Encode(b, p[:])
+	return b, nil
+}
+
+func (p *PublicKey) UnmarshalText(b []byte) error {
+	var v PublicKey
+	if err := decodeKey(v[:], b); err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if _, err := schnorr.ParsePubKey(v[:]); err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	*p = v
+	return nil
+}
+
+func decodeKey(dst, src []byte) error {
+	if len(src) != hex.EncodedLen(len(dst)) {
+		return fmt.Errorf("invalid hex length: %d", len(src))
+	}
+	_, err := hex.Decode(dst, src)
+	return err
+}
+
 // NewPrivateKey generates a new private key that is suitable
 // for use with secp256k1.
 func NewPrivateKey() (string, error) {
-	key, err := btcec.NewPrivateKey()
+	key, err := GeneratePrivateKey()
 	if err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(key.Serialize()), nil
+	return key.Hex(), nil
 }
 
 // PublicKeyFromPrivateKey returns the public key
 // that corresponds to the given private key.
 func PublicKeyFromPrivateKey(privKey string) (string, error) {
-	s, err := hex.DecodeString(privKey)
+	key, err := ParsePrivateKey(privKey)
 	if err != nil {
 		return "", err
 	}
-	_, pk := btcec.PrivKeyFromBytes(s)
-	return hex.EncodeToString(pk.SerializeCompressed()[1:]), nil
+	defer key.Zero()
+	return key.PublicKey().Hex(), nil
 }
@@ -0,0 +1,156 @@
+package nostr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// example keys from https://github.com/nostr-protocol/nips/blob/01f90d105d995df7308ef6bea46cc93cdef16ec3/19.md
+const (
+	testPrivKey = "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+	testPubKey  = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := ParsePrivateKey(testPrivKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() failed: %s", err)
+	}
+	if key.Hex() != testPrivKey {
+		t.Errorf("key.Hex() returned %s", key.Hex())
+	}
+	if pubKey := key.PublicKey().Hex(); pubKey != testPubKey {
+		t.Errorf("key.PublicKey() returned %s, expected %s", pubKey, testPubKey)
+	}
+
+	for _, tc := range []struct {
+		name string
+		s    string
+	}{
+		{name: "empty", s: ""},
+		{name: "31 bytes", s: testPrivKey[:62]},
+		{name: "33 bytes", s: testPrivKey + "00"},
+		{name: "not hex", s: strings.Repeat("x", 64)},
+		{name: "zero", s: strings.Repeat("0", 64)},
+		{name: "curve order", s: "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"},
+		{name: "higher than curve order", s: strings.Repeat("f", 64)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParsePrivateKey(tc.s); err == nil {
+				t.Errorf("ParsePrivateKey() succeeded")
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := ParsePublicKey(testPubKey)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() failed: %s", err)
+	}
+	if key.String() != testPubKey {
+		t.Errorf("key.String() returned %s", key.String())
+	}
+
+	for _, tc := range []struct {
+		name string
+		s    string
+	}{
+		{name: "empty", s: ""},
+		{name: "31 bytes", s: testPubKey[:62]},
+		{name: "compressed", s: "02" + testPubKey},
+		{name: "not hex", s: strings.Repeat("x", 64)},
+		{name: "not on curve", s: "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"},
+		{name: "higher than field prime", s: strings.Repeat("f", 64)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParsePublicKey(tc.s); err == nil {
+				t.Errorf("ParsePublicKey() succeeded")
+			}
+		})
+	}
+}
+
+func TestGeneratePrivateKey(t *testing.T) {
+	key1, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey() failed: %s", err)
+	}
+	key2, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey() failed: %s", err)
+	}
+	if key1.Equal(&key2) {
+		t.Errorf("generated keys are equal")
+	}
+	if _, err := ParsePrivateKey(key1.Hex()); err != nil {
+		t.Errorf("generated key is invalid: %s", err)
+	}
+}
+
+func TestPrivateKeyZero(t *testing.T) {
+	key, err := ParsePrivateKey(testPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Zero()
+	if !key.Equal(&PrivateKey{}) {
+		t.Errorf("key is not zeroed: %s", key.Hex())
+	}
+}
+
+func TestKeyMarshalText(t *testing.T) {
+	type keys struct {
+		Private PrivateKey `json:"private"`
+		Public  PublicKey  `json:"public"`
+	}
+
+	b := []byte(`{"private":"` + testPrivKey + `","public":"` + testPubKey + `"}`)
+	var v keys
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %s", err)
+	}
+	if !v.Public.Equal(v.Private.PublicKey()) {
+		t.Errorf("unexpected keys: %s", v.Public)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %s", err)
+	}
+	if string(out) != string(b) {
+		t.Errorf("json.Marshal() returned %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"private":"`+testPrivKey[:62]+`"}`), &v); err == nil {
+		t.Errorf("json.Unmarshal() succeeded with short key")
+	}
+}
+
+func TestEventSignWithKey(t *testing.T) {
+	key, err := ParsePrivateKey(testPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{
+		CreatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   "short text note",
+	}
+	if err := event.SignWithKey(&key); err != nil {
+		t.Fatalf("event.SignWithKey() failed: %s", err)
+	}
+	if event.PubKey != testPubKey {
+		t.Errorf("event.PubKey is %s, expected %s", event.PubKey, testPubKey)
+	}
+	if err := event.Verify(); err != nil {
+		t.Errorf("event.Verify() failed: %s", err)
+	}
+
+	if err := event.Sign(testPrivKey[:62]); err == nil {
+		t.Errorf("event.Sign() succeeded with short key")
+	}
+}
@@ -0,0 +1,113 @@
+// Package keysigner implements nostr.Signer with a private key held in memory.
+package keysigner
+
+import (
+	"context"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip04"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+var (
+	_ nostr.Signer         = (*Signer)(nil)
+	_ nostr.NIP04Encrypter = (*Signer)(nil)
+	_ nostr.NIP44Encrypter = (*Signer)(nil)
+)
+
+// A Signer signs events with a private key held in memory.
+type Signer struct {
+	key    nostr.PrivateKey
+	pubKey string
+}
+
+// New creates a new signer with the given private key.
+func New(key nostr.PrivateKey) *Signer {
+	return &Signer{
+		key:    key,
+		pubKey: key.PublicKey().Hex(),
+	}
+}
+
+// Parse creates a new signer with the given private key in hex.
+func Parse(privKey string) (*Signer, error) {
+	key, err := nostr.ParsePrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	return New(key), nil
+}
+
+func (s *Signer) PublicKey(context.Context) (string, error) {
+	return s.pubKey, nil
+}
+
+func (s *Signer) SignEvent(_ context.Context, event *nostr.Event) error {
+	return event.SignWithKey(&s.key)
+}
+
+func (s *Signer) NIP04Encrypt(_ context.Context, pubKey, plaintext string) (string, error) {
+	key, err := s.sharedSecret(pubKey)
+	if err != nil {
+		return "", err
+	}
+	defer zero(key)
+	return nip04.EncryptWithKey(key, plaintext)
+}
+
+func (s *Signer) NIP04Decrypt(_ context.Context, pubKey, ciphertext string) (string, error) {
+	key, err := s.sharedSecret(pubKey)
+	if err != nil {
+		return "", err
+	}
+	defer zero(key)
+	return nip04.DecryptWithKey(key, ciphertext)
+}
+
+func (s *Signer) NIP44Encrypt(_ context.Context, pubKey, plaintext string) (string, error) {
+	key, err := s.conversationKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	defer zero(key)
+	return nip44.EncryptWithKey(key, plaintext)
+}
+
+func (s *Signer) NIP44Decrypt(_ context.Context, pubKey, ciphertext string) (string, error) {
+	key, err := s.conversationKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	defer zero(key)
+	return nip44.DecryptWithKey(key, ciphertext)
+}
+
+// sharedSecret derives the NIP-04 shared secret with pubKey from the private key in place.
+func (s *Signer) sharedSecret(pubKey string) ([]byte, error) {
+	pk, err := nostr.ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.key.SharedSecret(pk)
+}
+
+// conversationKey derives the NIP-44 conversation key with pubKey from the private key in place.
+func (s *Signer) conversationKey(pubKey string) ([]byte, error) {
+	pk, err := nostr.ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return nip44.DeriveConversationKey(&s.key, pk)
+}
+
+// Zero overwrites the private key with zeros.
+// The signer must not be used after calling Zero.
+func (s *Signer) Zero() {
+	s.key.Zero()
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,88 @@
+package keysigner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+)
+
+func newSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := nostr.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(key)
+}
+
+func TestSignerSignEvent(t *testing.T) {
+	ctx := context.Background()
+
+	// example keys from NIP-19
+	signer, err := Parse("67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa")
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+	pubKey, err := signer.PublicKey(ctx)
+	if err != nil {
+		t.Fatalf("signer.PublicKey() failed: %s", err)
+	}
+	if pubKey != "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e" {
+		t.Errorf("signer.PublicKey() returned %s", pubKey)
+	}
+
+	event := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindTextNote,
+		Tags:      []nostr.Tag{},
+		Content:   "short text note",
+	}
+	if err := signer.SignEvent(ctx, event); err != nil {
+		t.Fatalf("signer.SignEvent() failed: %s", err)
+	}
+	if event.PubKey != pubKey {
+		t.Errorf("event.PubKey is %s, expected %s", event.PubKey, pubKey)
+	}
+	if err := event.Verify(); err != nil {
+		t.Errorf("event.Verify() failed: %s", err)
+	}
+}
+
+func TestParseInvalidKey(t *testing.T) {
+	if _, err := Parse("67dea2ed"); err == nil {
+		t.Errorf("Parse() succeeded with short key")
+	}
+}
+
+func TestSignerEncrypt(t *testing.T) {
+	ctx := context.Background()
+	alice := newSigner(t)
+	bob := newSigner(t)
+	alicePub, _ := alice.PublicKey(ctx)
+	bobPub, _ := bob.PublicKey(ctx)
+
+	for _, tc := range []struct {
+		name    string
+		encrypt func(context.Context, string, string) (string, error)
+		decrypt func(context.Context, string, string) (string, error)
+	}{
+		{name: "nip04", encrypt: alice.NIP04Encrypt, decrypt: bob.NIP04Decrypt},
+		{name: "nip44", encrypt: alice.NIP44Encrypt, decrypt: bob.NIP44Decrypt},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ciphertext, err := tc.encrypt(ctx, bobPub, "hello")
+			if err != nil {
+				t.Fatalf("encrypt failed: %s", err)
+			}
+			plaintext, err := tc.decrypt(ctx, alicePub, ciphertext)
+			if err != nil {
+				t.Fatalf("decrypt failed: %s", err)
+			}
+			if plaintext != "hello" {
+				t.Errorf("decrypt returned %q", plaintext)
+			}
+		})
+	}
+}
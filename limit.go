@@ -0,0 +1,173 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// relayInfoTimeout is the time limit of fetching relay information on connection.
+const relayInfoTimeout = 5 * time.Second
+
+// A LimitError is returned when a message exceeds a limitation of the relay server.
+// Such a message is not sent to the relay server.
+type LimitError struct {
+	// Limit is the name of the limitation in the relay information document, such as "max_event_tags".
+	Limit  string
+	Max    int
+	Actual int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("relay limitation exceeded: %s: %d > %d", e.Limit, e.Actual, e.Max)
+}
+
+// Limitation returns the limitation of the relay server enforced by the client.
+// It returns nil if no limitation is enforced.
+func (c *Client) Limitation() *RelayLimitation {
+	return c.limitation
+}
+
+// fetchLimitation fetches the limitation of the relay server.
+// Failures are ignored since relay servers are not required to serve relay information.
+func (c *Client) fetchLimitation() {
+	ctx, cancel := context.WithTimeout(c.ctx, relayInfoTimeout)
+	defer cancel()
+	info, err := FetchRelayInfo(ctx, c.url)
+	if err != nil {
+		return
+	}
+	c.limitation = info.Limitation
+}
+
+// checkEvent returns a *LimitError if the event exceeds the limitation.
+func (c *Client) checkEvent(event *Event) error {
+	l := c.limitation
+	if l == nil {
+		return nil
+	}
+	if l.MaxEventTags > 0 && len(event.Tags) > l.MaxEventTags {
+		return &LimitError{Limit: "max_event_tags", Max: l.MaxEventTags, Actual: len(event.Tags)}
+	}
+	if l.MaxContentLength > 0 {
+		if n := utf8.RuneCountInString(event.Content); n > l.MaxContentLength {
+			return &LimitError{Limit: "max_content_length", Max: l.MaxContentLength, Actual: n}
+		}
+	}
+	return nil
+}
+
+// checkMessage returns a *LimitError if the encoded message exceeds the limitation.
+func (c *Client) checkMessage(b []byte) error {
+	l := c.limitation
+	if l == nil || l.MaxMessageLength <= 0 || len(b) <= l.MaxMessageLength {
+		return nil
+	}
+	return &LimitError{Limit: "max_message_length", Max: l.MaxMessageLength, Actual: len(b)}
+}
+
+// limitFilters clamps the limit of filters to the limitation
+// and splits them into groups sent in separate requests.
+func (c *Client) limitFilters(filters []Filter) ([][]Filter, error) {
+	l := c.limitation
+	if l == nil {
+		return [][]Filter{filters}, nil
+	}
+
+	if l.MaxLimit > 0 {
+		clamped := make([]Filter, len(filters))
+		for i, f := range filters {
+			if f.Limit > l.MaxLimit {
+				f.Limit = l.MaxLimit
+			}
+			clamped[i] = f
+		}
+		filters = clamped
+	}
+
+	if l.MaxFilters <= 0 || len(filters) <= l.MaxFilters {
+		return [][]Filter{filters}, nil
+	}
+	var groups [][]Filter
+	for len(filters) > 0 {
+		n := l.MaxFilters
+		if n > len(filters) {
+			n = len(filters)
+		}
+		groups = append(groups, filters[:n])
+		filters = filters[n:]
+	}
+	if l.MaxSubscriptions > 0 && len(groups) > l.MaxSubscriptions {
+		return nil, &LimitError{Limit: "max_subscriptions", Max: l.MaxSubscriptions, Actual: len(groups)}
+	}
+	return groups, nil
+}
+
+// subscriptionSlots limits the number of concurrent subscriptions.
+// A nil *subscriptionSlots imposes no limitation.
+type subscriptionSlots struct {
+	max int
+
+	mu      sync.Mutex
+	used    int
+	changed chan struct{} // closed when a slot is released
+}
+
+func newSubscriptionSlots(l *RelayLimitation) *subscriptionSlots {
+	if l == nil || l.MaxSubscriptions <= 0 {
+		return nil
+	}
+	return &subscriptionSlots{
+		max:     l.MaxSubscriptions,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits for n slots to be available at once and returns functions to release each of them.
+// Slots are never held while waiting for others,
+// so that subscriptions split into several requests do not deadlock each other.
+func (s *subscriptionSlots) acquire(ctx context.Context, n int) ([]func(), error) {
+	releases := make([]func(), n)
+	if s == nil {
+		for i := range releases {
+			releases[i] = func() {}
+		}
+		return releases, nil
+	}
+	if n > s.max {
+		return nil, &LimitError{Limit: "max_subscriptions", Max: s.max, Actual: n}
+	}
+
+	for {
+		s.mu.Lock()
+		if s.used+n <= s.max {
+			s.used += n
+			s.mu.Unlock()
+			break
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for subscription slot: %w", ctx.Err())
+		}
+	}
+
+	for i := range releases {
+		var once sync.Once
+		releases[i] = func() { once.Do(s.release) }
+	}
+	return releases, nil
+}
+
+func (s *subscriptionSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used--
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
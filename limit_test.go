@@ -0,0 +1,386 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// newLimitTestServer returns a relay server that serves the limitation in its relay information document
+// and sends received requests to reqs.
+func newLimitTestServer(limitation *RelayLimitation, reqs chan<- *ReqMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == RelayInfoContentType {
+			json.NewEncoder(w).Encode(&RelayInfo{Limitation: limitation})
+			return
+		}
+
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var b json.RawMessage
+			if err := wsjson.Read(ctx, conn, &b); err != nil {
+				return
+			}
+			typ, err := ParseMessageType(b)
+			if err != nil || typ != MessageTypeReq {
+				continue
+			}
+			var m ReqMessage
+			if err := m.UnmarshalJSON(b); err != nil {
+				return
+			}
+			reqs <- &m
+			if err := wsjson.Write(ctx, conn, []any{"EOSE", m.SubscriptionID}); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestClientWithRelayLimitationPublish(t *testing.T) {
+	server := newPoolTestServer(nil)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRelayLimitation(&RelayLimitation{
+		MaxMessageLength: 1000,
+		MaxEventTags:     2,
+		MaxContentLength: 10,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	signer := newTestSigner(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		name    string
+		tags    []Tag
+		content string
+		limit   string
+	}{
+		{name: "valid", tags: []Tag{{"t", "nostr"}}, content: "ノストル"},
+		{name: "too many tags", tags: []Tag{{"t", "a"}, {"t", "b"}, {"t", "c"}}, limit: "max_event_tags"},
+		{name: "too long content", tags: []Tag{}, content: "long text note", limit: "max_content_length"},
+		{name: "too long message", tags: []Tag{{"t", strings.Repeat("a", 1000)}}, limit: "max_message_length"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &Event{
+				CreatedAt: time.Now().Unix(),
+				Kind:      EventKindTextNote,
+				Tags:      tc.tags,
+				Content:   tc.content,
+			}
+			result, err := client.SignAndPublish(ctx, signer, event)
+			if tc.limit == "" {
+				if err != nil {
+					t.Fatalf("client.SignAndPublish() failed: %s", err)
+				}
+				if !result.OK {
+					t.Errorf("unexpected result: %+v", result)
+				}
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("client.SignAndPublish() returned %v, expected limit error", err)
+			}
+			if limitErr.Limit != tc.limit {
+				t.Errorf("unexpected limit: %s", limitErr.Limit)
+			}
+		})
+	}
+}
+
+func TestClientWithRelayInfoLimitationSubscribe(t *testing.T) {
+	reqs := make(chan *ReqMessage, 10)
+	server := newLimitTestServer(&RelayLimitation{
+		MaxSubscriptions: 2,
+		MaxFilters:       2,
+		MaxLimit:         100,
+	}, reqs)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRelayInfoLimitation())
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+	if l := client.Limitation(); l == nil || l.MaxSubscriptions != 2 {
+		t.Fatalf("unexpected limitation: %+v", l)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("too many filters", func(t *testing.T) {
+		filters := make([]Filter, 5)
+		var limitErr *LimitError
+		if _, err := client.Subscribe(ctx, filters); !errors.As(err, &limitErr) || limitErr.Limit != "max_subscriptions" {
+			t.Errorf("client.Subscribe() returned %v, expected limit error", err)
+		}
+	})
+
+	// filters are split into two requests, which occupy all subscription slots
+	sub, err := client.Subscribe(ctx, []Filter{{Limit: 1000}, {Limit: 10}, {}})
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	subCtx, subCancel := context.WithCancel(ctx)
+	subDone := make(chan error, 1)
+	go func() {
+		subDone <- sub.Receive(subCtx, func(context.Context, *Event) {})
+	}()
+
+	var filters []Filter
+	for i := 0; i < 2; i++ {
+		select {
+		case req := <-reqs:
+			if len(req.Filters) > 2 {
+				t.Errorf("too many filters in a request: %d", len(req.Filters))
+			}
+			filters = append(filters, req.Filters...)
+		case <-ctx.Done():
+			t.Fatal("missing request")
+		}
+	}
+	if len(filters) != 3 {
+		t.Fatalf("unexpected number of filters: %d", len(filters))
+	}
+	for _, f := range filters {
+		if f.Limit > 100 {
+			t.Errorf("limit is not clamped: %d", f.Limit)
+		}
+	}
+
+	// another subscription waits for a slot
+	queued, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	queuedCtx, queuedCancel := context.WithCancel(ctx)
+	defer queuedCancel()
+	go queued.Receive(queuedCtx, func(context.Context, *Event) {})
+
+	select {
+	case req := <-reqs:
+		t.Fatalf("request is sent over max_subscriptions: %+v", req)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	subCancel()
+	if err := <-subDone; err != nil {
+		t.Fatalf("sub.Receive() failed: %s", err)
+	}
+	select {
+	case req := <-reqs:
+		if req.SubscriptionID != queued.ID() {
+			t.Errorf("unexpected request: %+v", req)
+		}
+	case <-ctx.Done():
+		t.Fatal("queued request is not sent")
+	}
+}
+
+func TestClientSubscribeSplitConcurrently(t *testing.T) {
+	reqs := make(chan *ReqMessage, 10)
+	server := newLimitTestServer(nil, reqs)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRelayLimitation(&RelayLimitation{
+		MaxSubscriptions: 2,
+		MaxFilters:       1,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// each subscription is split into two requests occupying all subscription slots
+	var wg sync.WaitGroup
+	var eose atomic.Int32
+	for i := 0; i < 2; i++ {
+		sub, err := client.Subscribe(ctx, []Filter{{Kinds: []EventKind{EventKindTextNote}}, {Kinds: []EventKind{EventKindReaction}}})
+		if err != nil {
+			t.Fatalf("client.Subscribe() failed: %s", err)
+		}
+
+		subCtx, subCancel := context.WithCancel(ctx)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer subCancel()
+			select {
+			case <-sub.EOSE():
+				eose.Add(1)
+			case <-subCtx.Done():
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := sub.Receive(subCtx, func(context.Context, *Event) {}); err != nil {
+				t.Errorf("sub.Receive() failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := eose.Load(); n != 2 {
+		t.Errorf("%d of 2 subscriptions reached EOSE", n)
+	}
+}
+
+func TestClientSubscribeSplitClosed(t *testing.T) {
+	closes := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var b json.RawMessage
+			if err := wsjson.Read(ctx, conn, &b); err != nil {
+				return
+			}
+			typ, err := ParseMessageType(b)
+			if err != nil {
+				return
+			}
+			switch typ {
+			case MessageTypeReq:
+				var m ReqMessage
+				if err := m.UnmarshalJSON(b); err != nil {
+					return
+				}
+				// refuse requests for reactions only
+				message := []any{"EOSE", m.SubscriptionID}
+				if m.Filters[0].Kinds[0] == EventKindReaction {
+					message = []any{"CLOSED", m.SubscriptionID, "restricted: no reactions"}
+				}
+				if err := wsjson.Write(ctx, conn, message); err != nil {
+					return
+				}
+			case MessageTypeClose:
+				var m CloseMessage
+				if err := m.UnmarshalJSON(b); err != nil {
+					return
+				}
+				closes <- m.SubscriptionID
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRelayLimitation(&RelayLimitation{
+		MaxSubscriptions: 2,
+		MaxFilters:       1,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filters := []Filter{{Kinds: []EventKind{EventKindTextNote}}, {Kinds: []EventKind{EventKindReaction}}}
+	sub, err := client.Subscribe(ctx, filters)
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	err = sub.Receive(ctx, func(context.Context, *Event) {})
+	var closedErr *ClosedError
+	if !errors.As(err, &closedErr) || closedErr.Reason != ReasonRestricted {
+		t.Fatalf("sub.Receive() returned %v, expected closed error", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("sub.Receive() returned after context is done")
+	}
+
+	// the other request is closed and its slot is released
+	select {
+	case <-closes:
+	case <-ctx.Done():
+		t.Fatal("the other request is not closed")
+	}
+	sub, err = client.Subscribe(ctx, filters[:1])
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	subCtx, subCancel := context.WithCancel(ctx)
+	defer subCancel()
+	go sub.Receive(subCtx, func(context.Context, *Event) {})
+	select {
+	case <-sub.EOSE():
+	case <-ctx.Done():
+		t.Fatal("subscription slots are not released")
+	}
+}
+
+func TestClientWithRelayLimitationCount(t *testing.T) {
+	reqs := make(chan *ReqMessage, 10)
+	server := newLimitTestServer(nil, reqs)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, WithRelayLimitation(&RelayLimitation{
+		MaxSubscriptions: 1,
+		MaxFilters:       1,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient() failed: %s", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var limitErr *LimitError
+	if _, err := client.CountResult(ctx, []Filter{{}, {}}); !errors.As(err, &limitErr) || limitErr.Limit != "max_filters" {
+		t.Errorf("client.CountResult() returned %v, expected limit error", err)
+	}
+
+	// a count request waits for the slot occupied by the subscription
+	sub, err := client.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatalf("client.Subscribe() failed: %s", err)
+	}
+	go sub.Receive(ctx, func(context.Context, *Event) {})
+	select {
+	case <-reqs:
+	case <-ctx.Done():
+		t.Fatal("missing request")
+	}
+
+	countCtx, countCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer countCancel()
+	if _, err := client.CountResult(countCtx, []Filter{{}}); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "subscription slot") {
+		t.Errorf("client.CountResult() returned %v, expected error waiting for subscription slot", err)
+	}
+}
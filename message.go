@@ -2,6 +2,8 @@ package nostr
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 )
 
 // MessageType is the type of a message.
@@ -14,8 +16,24 @@ const (
 	MessageTypeNotice MessageType = "NOTICE" // NIP-01
 	MessageTypeEOSE   MessageType = "EOSE"   // NIP-01
 	MessageTypeOK     MessageType = "OK"     // NIP-20
+	MessageTypeClosed MessageType = "CLOSED" // NIP-01
+	MessageTypeAuth   MessageType = "AUTH"   // NIP-42
+	MessageTypeCount  MessageType = "COUNT"  // NIP-45
 )
 
+// ParseMessageType returns the type of the given raw message.
+func ParseMessageType(b []byte) (MessageType, error) {
+	message, err := splitMessage(b)
+	if err != nil {
+		return "", err
+	}
+	var typ string
+	if err = json.Unmarshal(message[0], &typ); err != nil {
+		return "", err
+	}
+	return MessageType(typ), nil
+}
+
 // A EventMessagek is an event message.
 // It's used to publish events from clients
 // or to send events requested to clients
@@ -37,6 +55,32 @@ func (m *EventMessage) MarshalJSON() ([]byte, error) {
 	return b, nil
 }
 
+func (m *EventMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeEvent)
+	if err != nil {
+		return err
+	}
+
+	var subID string
+	switch len(message) {
+	case 2:
+	case 3:
+		if err = json.Unmarshal(message[1], &subID); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid event message length: %d", len(message))
+	}
+	var event Event
+	if err = json.Unmarshal(message[len(message)-1], &event); err != nil {
+		return err
+	}
+
+	m.SubscriptionID = subID
+	m.Event = &event
+	return nil
+}
+
 // A ReqMessage is a request message.
 // It's used to request events and subscribe to new updates.
 type ReqMessage struct {
@@ -56,6 +100,31 @@ func (m *ReqMessage) MarshalJSON() ([]byte, error) {
 	return b, nil
 }
 
+func (m *ReqMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeReq)
+	if err != nil {
+		return err
+	}
+	if len(message) < 3 {
+		return fmt.Errorf("invalid req message length: %d", len(message))
+	}
+
+	var subID string
+	if err = json.Unmarshal(message[1], &subID); err != nil {
+		return err
+	}
+	filters := make([]Filter, len(message)-2)
+	for i, raw := range message[2:] {
+		if err = json.Unmarshal(raw, &filters[i]); err != nil {
+			return err
+		}
+	}
+
+	m.SubscriptionID = subID
+	m.Filters = filters
+	return nil
+}
+
 // A CloseMessage is a close message.
 // It's used to stop previous subscriptions.
 type CloseMessage struct {
@@ -70,18 +139,88 @@ func (m *CloseMessage) MarshalJSON() ([]byte, error) {
 	return b, nil
 }
 
+func (m *CloseMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeClose)
+	if err != nil {
+		return err
+	}
+	if len(message) != 2 {
+		return fmt.Errorf("invalid close message length: %d", len(message))
+	}
+
+	var subID string
+	if err = json.Unmarshal(message[1], &subID); err != nil {
+		return err
+	}
+
+	m.SubscriptionID = subID
+	return nil
+}
+
 // A NoticeMessage is a notice message.
 // It's used to send human-readable messages to clients.
 type NoticeMessage struct {
 	Message string
 }
 
+func (m *NoticeMessage) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal([]any{MessageTypeNotice, m.Message})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *NoticeMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeNotice)
+	if err != nil {
+		return err
+	}
+	if len(message) != 2 {
+		return fmt.Errorf("invalid notice message length: %d", len(message))
+	}
+
+	var s string
+	if err = json.Unmarshal(message[1], &s); err != nil {
+		return err
+	}
+
+	m.Message = s
+	return nil
+}
+
 // A EOSEMessage is a EOSE message.
 // It's used to notify clients all stored events have been sent.
 type EOSEMessage struct {
 	SubscriptionID string
 }
 
+func (m *EOSEMessage) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal([]any{MessageTypeEOSE, m.SubscriptionID})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *EOSEMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeEOSE)
+	if err != nil {
+		return err
+	}
+	if len(message) != 2 {
+		return fmt.Errorf("invalid EOSE message length: %d", len(message))
+	}
+
+	var subID string
+	if err = json.Unmarshal(message[1], &subID); err != nil {
+		return err
+	}
+
+	m.SubscriptionID = subID
+	return nil
+}
+
 // A OKMessage is a OK message.
 // It's used to notify clients if an EVENT was successful.
 type OKMessage struct {
@@ -89,3 +228,230 @@ type OKMessage struct {
 	OK      bool
 	Message string
 }
+
+func (m *OKMessage) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal([]any{MessageTypeOK, m.EventID, m.OK, m.Message})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *OKMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeOK)
+	if err != nil {
+		return err
+	}
+	if len(message) != 4 {
+		return fmt.Errorf("invalid OK message length: %d", len(message))
+	}
+
+	var eventID string
+	if err = json.Unmarshal(message[1], &eventID); err != nil {
+		return err
+	}
+	var ok bool
+	if err = json.Unmarshal(message[2], &ok); err != nil {
+		return err
+	}
+	var s string
+	if err = json.Unmarshal(message[3], &s); err != nil {
+		return err
+	}
+
+	m.EventID = eventID
+	m.OK = ok
+	m.Message = s
+	return nil
+}
+
+// A ClosedMessage is a closed message.
+// It's used to notify clients that a subscription is ended on the server side.
+type ClosedMessage struct {
+	SubscriptionID string
+	Message        string
+}
+
+func (m *ClosedMessage) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal([]any{MessageTypeClosed, m.SubscriptionID, m.Message})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *ClosedMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeClosed)
+	if err != nil {
+		return err
+	}
+	if len(message) != 3 {
+		return fmt.Errorf("invalid closed message length: %d", len(message))
+	}
+
+	var subID string
+	if err = json.Unmarshal(message[1], &subID); err != nil {
+		return err
+	}
+	var s string
+	if err = json.Unmarshal(message[2], &s); err != nil {
+		return err
+	}
+
+	m.SubscriptionID = subID
+	m.Message = s
+	return nil
+}
+
+// A AuthMessage is an auth message.
+// Relay servers send it with a challenge to request authentication,
+// and clients send it with a signed kind 22242 event to authenticate.
+// Exactly one of Challenge and Event is set.
+type AuthMessage struct {
+	Challenge string
+	Event     *Event
+}
+
+func (m *AuthMessage) MarshalJSON() ([]byte, error) {
+	body := []any{MessageTypeAuth, m.Challenge}
+	if m.Event != nil {
+		body = []any{MessageTypeAuth, m.Event}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *AuthMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeAuth)
+	if err != nil {
+		return err
+	}
+	if len(message) != 2 {
+		return fmt.Errorf("invalid auth message length: %d", len(message))
+	}
+
+	var challenge string
+	if err = json.Unmarshal(message[1], &challenge); err == nil {
+		m.Challenge = challenge
+		m.Event = nil
+		return nil
+	}
+	var event Event
+	if err = json.Unmarshal(message[1], &event); err != nil {
+		return err
+	}
+
+	m.Challenge = ""
+	m.Event = &event
+	return nil
+}
+
+// A CountResult is a result of a count request.
+type CountResult struct {
+	Count int64 `json:"count"`
+	// Approximate reports whether Count is an estimate.
+	Approximate bool `json:"approximate,omitempty"`
+	// HLL is the hex-encoded HyperLogLog registers
+	// which clients can merge to count events across relay servers.
+	HLL string `json:"hll,omitempty"`
+}
+
+// A CountMessage is a count message.
+// Clients send it with filters to request the number of matching events,
+// and relay servers send it back with the result.
+// Exactly one of Filters and Result is set.
+type CountMessage struct {
+	SubscriptionID string
+	Filters        []Filter
+	Result         *CountResult
+}
+
+func (m *CountMessage) MarshalJSON() ([]byte, error) {
+	body := []any{MessageTypeCount, m.SubscriptionID}
+	if m.Result != nil {
+		body = append(body, m.Result)
+	} else {
+		for _, f := range m.Filters {
+			body = append(body, f)
+		}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (m *CountMessage) UnmarshalJSON(b []byte) error {
+	message, err := splitTypedMessage(b, MessageTypeCount)
+	if err != nil {
+		return err
+	}
+	if len(message) < 3 {
+		return fmt.Errorf("invalid count message length: %d", len(message))
+	}
+
+	var subID string
+	if err = json.Unmarshal(message[1], &subID); err != nil {
+		return err
+	}
+
+	// a result always has the count field, which filters never have
+	var result struct {
+		Count *int64 `json:"count"`
+		CountResult
+	}
+	if len(message) == 3 {
+		if err = json.Unmarshal(message[2], &result); err != nil {
+			return err
+		}
+	}
+	if result.Count != nil {
+		result.CountResult.Count = *result.Count
+		m.SubscriptionID = subID
+		m.Filters = nil
+		m.Result = &result.CountResult
+		return nil
+	}
+
+	filters := make([]Filter, len(message)-2)
+	for i, raw := range message[2:] {
+		if err = json.Unmarshal(raw, &filters[i]); err != nil {
+			return err
+		}
+	}
+
+	m.SubscriptionID = subID
+	m.Filters = filters
+	m.Result = nil
+	return nil
+}
+
+func splitMessage(b []byte) ([]json.RawMessage, error) {
+	var message []json.RawMessage
+	if err := json.Unmarshal(b, &message); err != nil {
+		return nil, err
+	}
+	if len(message) == 0 {
+		return nil, errors.New("empty message")
+	}
+	return message, nil
+}
+
+func splitTypedMessage(b []byte, typ MessageType) ([]json.RawMessage, error) {
+	message, err := splitMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	var s string
+	if err = json.Unmarshal(message[0], &s); err != nil {
+		return nil, err
+	}
+	if s != string(typ) {
+		return nil, fmt.Errorf("unexpected message type: %s", s)
+	}
+	return message, nil
+}
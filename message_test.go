@@ -1,6 +1,7 @@
 package nostr
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 )
@@ -94,3 +95,206 @@ func TestCloseMessageMarshalJSON(t *testing.T) {
 		t.Errorf("message.MarshalJSON() failed: expected %s, got %s", expected, string(b))
 	}
 }
+
+func TestCountMessageMarshalJSON(t *testing.T) {
+	message := CountMessage{
+		SubscriptionID: "sub-id",
+		Filters:        []Filter{{Kinds: []EventKind{EventKindReaction}}},
+	}
+
+	expected := `["COUNT","sub-id",{"kinds":[7]}]`
+
+	b, err := message.MarshalJSON()
+	if err != nil {
+		t.Fatalf("message.MarshalJSON() failed: %s", err)
+	}
+	if string(b) != expected {
+		t.Errorf("message.MarshalJSON() failed: expected %s, got %s", expected, string(b))
+	}
+}
+
+func TestReqMessageMarshalJSONWithTags(t *testing.T) {
+	message := ReqMessage{
+		SubscriptionID: "sub-id",
+		Filters: []Filter{
+			{
+				Kinds: []EventKind{EventKindTextNote},
+				Tags:  []Tag{{"t", "nostr"}},
+			},
+		},
+	}
+
+	expected := `["REQ","sub-id",{"kinds":[1],"#t":["nostr"]}]`
+
+	b, err := message.MarshalJSON()
+	if err != nil {
+		t.Fatalf("message.MarshalJSON() failed: %s", err)
+	}
+	if string(b) != expected {
+		t.Errorf("message.MarshalJSON() failed: expected %s, got %s", expected, string(b))
+	}
+}
+
+func TestMessageUnmarshalJSON(t *testing.T) {
+	t.Run("event message", func(t *testing.T) {
+		var m EventMessage
+		b := `["EVENT","sub-id",{"id":"f926f5","pubkey":"7e7e9c","created_at":1672531200,"kind":1,"tags":[],"content":"short text note","sig":"7903b4"}]`
+		if err := m.UnmarshalJSON([]byte(b)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" || m.Event.ID != "f926f5" || m.Event.Content != "short text note" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+
+		b = `["EVENT",{"id":"f926f5","pubkey":"7e7e9c","created_at":1672531200,"kind":1,"tags":[],"content":"short text note","sig":"7903b4"}]`
+		if err := m.UnmarshalJSON([]byte(b)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "" || m.Event.ID != "f926f5" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("req message", func(t *testing.T) {
+		var m ReqMessage
+		if err := m.UnmarshalJSON([]byte(`["REQ","sub-id",{"kinds":[1]},{"#t":["nostr"]}]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" || len(m.Filters) != 2 || m.Filters[1].Tags[0][1] != "nostr" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("close message", func(t *testing.T) {
+		var m CloseMessage
+		if err := m.UnmarshalJSON([]byte(`["CLOSE","sub-id"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("notice message", func(t *testing.T) {
+		var m NoticeMessage
+		if err := m.UnmarshalJSON([]byte(`["NOTICE","human-readable message"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.Message != "human-readable message" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("EOSE message", func(t *testing.T) {
+		var m EOSEMessage
+		if err := m.UnmarshalJSON([]byte(`["EOSE","sub-id"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("OK message", func(t *testing.T) {
+		var m OKMessage
+		if err := m.UnmarshalJSON([]byte(`["OK","f926f5",false,"invalid: bad signature"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.EventID != "f926f5" || m.OK || m.Message != "invalid: bad signature" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("closed message", func(t *testing.T) {
+		var m ClosedMessage
+		if err := m.UnmarshalJSON([]byte(`["CLOSED","sub-id","auth-required: we only serve authenticated users"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" || m.Message != "auth-required: we only serve authenticated users" {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("auth message", func(t *testing.T) {
+		var m AuthMessage
+		if err := m.UnmarshalJSON([]byte(`["AUTH","challenge-string"]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.Challenge != "challenge-string" || m.Event != nil {
+			t.Errorf("unexpected message: %+v", m)
+		}
+
+		b := `["AUTH",{"id":"f926f5","pubkey":"7e7e9c","created_at":1672531200,"kind":22242,"tags":[["relay","wss://relay.example.com"],["challenge","challenge-string"]],"content":"","sig":"7903b4"}]`
+		if err := m.UnmarshalJSON([]byte(b)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.Challenge != "" || m.Event.ID != "f926f5" || m.Event.Kind != EventKindClientAuthentication {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("count message", func(t *testing.T) {
+		var m CountMessage
+		if err := m.UnmarshalJSON([]byte(`["COUNT","sub-id",{"kinds":[3],"#p":["7e7e9c"]}]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.SubscriptionID != "sub-id" || len(m.Filters) != 1 || m.Filters[0].Kinds[0] != EventKindContacts || m.Result != nil {
+			t.Errorf("unexpected message: %+v", m)
+		}
+
+		if err := m.UnmarshalJSON([]byte(`["COUNT","sub-id",{"count":93412452,"approximate":true,"hll":"0607"}]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		expected := &CountResult{Count: 93412452, Approximate: true, HLL: "0607"}
+		if m.SubscriptionID != "sub-id" || m.Filters != nil || m.Result == nil || *m.Result != *expected {
+			t.Errorf("unexpected message: %+v", m)
+		}
+
+		if err := m.UnmarshalJSON([]byte(`["COUNT","sub-id",{"count":0}]`)); err != nil {
+			t.Fatalf("message.UnmarshalJSON() failed: %s", err)
+		}
+		if m.Result == nil || m.Result.Count != 0 {
+			t.Errorf("unexpected message: %+v", m)
+		}
+	})
+
+	t.Run("invalid messages", func(t *testing.T) {
+		for _, b := range []string{
+			`[]`,
+			`{}`,
+			`["NOTICE","message"]`,
+			`["EOSE"]`,
+			`["EOSE","sub-id","extra"]`,
+		} {
+			var m EOSEMessage
+			if err := m.UnmarshalJSON([]byte(b)); err == nil {
+				t.Errorf("message.UnmarshalJSON(%s) succeeded unexpectedly", b)
+			}
+		}
+	})
+}
+
+func TestRelayMessageMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		message  json.Marshaler
+		expected string
+	}{
+		{"notice message", &NoticeMessage{Message: "human-readable message"}, `["NOTICE","human-readable message"]`},
+		{"EOSE message", &EOSEMessage{SubscriptionID: "sub-id"}, `["EOSE","sub-id"]`},
+		{"OK message", &OKMessage{EventID: "f926f5", OK: true, Message: ""}, `["OK","f926f5",true,""]`},
+		{"closed message", &ClosedMessage{SubscriptionID: "sub-id", Message: "error: shutting down"}, `["CLOSED","sub-id","error: shutting down"]`},
+		{"count message", &CountMessage{SubscriptionID: "sub-id", Result: &CountResult{Count: 238}}, `["COUNT","sub-id",{"count":238}]`},
+		{"auth message", &AuthMessage{Challenge: "challenge-string"}, `["AUTH","challenge-string"]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.message.MarshalJSON()
+			if err != nil {
+				t.Fatalf("message.MarshalJSON() failed: %s", err)
+			}
+			if string(b) != tc.expected {
+				t.Errorf("message.MarshalJSON() failed: expected %s, got %s", tc.expected, string(b))
+			}
+		})
+	}
+}
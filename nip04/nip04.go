@@ -0,0 +1,185 @@
+// Package nip04 implements encrypted direct messages defined in NIP-04.
+//
+// NIP-04 is deprecated in favor of NIP-44 and NIP-17
+// because it leaks metadata of messages. Use it only for compatibility.
+package nip04
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+)
+
+// Encrypt encrypts plaintext with the shared secret between privKey and pubKey.
+// The result is in the form of "<base64 ciphertext>?iv=<base64 iv>".
+func Encrypt(privKey, pubKey, plaintext string) (string, error) {
+	key, err := sharedSecret(privKey, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return EncryptWithKey(key, plaintext)
+}
+
+// EncryptWithKey encrypts plaintext with the shared secret
+// returned by nostr.PrivateKey.SharedSecret.
+func EncryptWithKey(sharedSecret []byte, plaintext string) (string, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+	padded := pad([]byte(plaintext), aes.BlockSize)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return base64.StdEncoding.EncodeToString(ciphertext) + "?iv=" + base64.StdEncoding.EncodeToString(iv), nil
+}
+
+// Decrypt decrypts content encrypted by Encrypt with the shared secret between privKey and pubKey.
+func Decrypt(privKey, pubKey, content string) (string, error) {
+	key, err := sharedSecret(privKey, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return DecryptWithKey(key, content)
+}
+
+// DecryptWithKey decrypts content encrypted by Encrypt with the shared secret
+// returned by nostr.PrivateKey.SharedSecret.
+func DecryptWithKey(sharedSecret []byte, content string) (string, error) {
+	encodedCiphertext, encodedIV, ok := strings.Cut(content, "?iv=")
+	if !ok {
+		return "", errors.New("invalid content: missing iv")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encodedCiphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(encodedIV)
+	if err != nil {
+		return "", fmt.Errorf("invalid iv: %w", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("invalid iv length: %d", len(iv))
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("invalid ciphertext length: %d", len(ciphertext))
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return "", err
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+
+	plaintext, err := unpad(padded, aes.BlockSize)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NewDirectMessage creates an encrypted direct message event
+// from the owner of privKey to the owner of recipientPubKey.
+// The event is signed with privKey.
+func NewDirectMessage(privKey, recipientPubKey, plaintext string) (*nostr.Event, error) {
+	content, err := Encrypt(privKey, recipientPubKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindEncryptedDirectMessages,
+		Tags:      []nostr.Tag{{"p", recipientPubKey}},
+		Content:   content,
+	}
+	if err := event.Sign(privKey); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// DecryptDirectMessage decrypts an encrypted direct message event
+// sent or received by the owner of privKey.
+func DecryptDirectMessage(privKey string, event *nostr.Event) (string, error) {
+	if event.Kind != nostr.EventKindEncryptedDirectMessages {
+		return "", fmt.Errorf("unexpected event kind: %d", event.Kind)
+	}
+
+	var recipient string
+	for _, tag := range event.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			recipient = tag[1]
+			break
+		}
+	}
+	if recipient == "" {
+		return "", errors.New("missing recipient")
+	}
+
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	// the counterparty is the recipient if the message is sent by the owner of privKey
+	counterparty := event.PubKey
+	if event.PubKey == pubKey {
+		counterparty = recipient
+	} else if recipient != pubKey {
+		return "", errors.New("message is not addressed to the key")
+	}
+
+	return Decrypt(privKey, counterparty, event.Content)
+}
+
+// sharedSecret returns the x coordinate of the ECDH shared point.
+func sharedSecret(privKey, pubKey string) ([]byte, error) {
+	sk, err := nostr.ParsePrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Zero()
+	pk, err := nostr.ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return sk.SharedSecret(pk)
+}
+
+// pad applies PKCS#7 padding.
+func pad(b []byte, size int) []byte {
+	n := size - len(b)%size
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// unpad removes PKCS#7 padding.
+func unpad(b []byte, size int) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("invalid padding")
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > size || n > len(b) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return b[:len(b)-n], nil
+}
@@ -0,0 +1,129 @@
+package nip04
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+)
+
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, pubKey
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alicePriv, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+
+	for i := 0; i < 40; i++ {
+		plaintext := strings.Repeat("a", i)
+		content, err := Encrypt(alicePriv, bobPub, plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt() failed: %s", err)
+		}
+		if !strings.Contains(content, "?iv=") {
+			t.Fatalf("unexpected content: %s", content)
+		}
+
+		decrypted, err := Decrypt(bobPriv, alicePub, content)
+		if err != nil {
+			t.Fatalf("Decrypt() failed: %s", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt() returned %q, expected %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestDecryptCompatibility(t *testing.T) {
+	// payload encrypted by nostr-tools
+	privKey := "92996316beebf94171065a714cbf164d1f56d7ad9b35b329d9fc97535bf25352"
+	pubKey, err := nostr.PublicKeyFromPrivateKey("591c0c249adfb9346f8d37dfeed65725e2eea1d7a6e99fa503342f367138de84")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := Decrypt(privKey, pubKey, "A+fRnU4aXS4kbTLfowqAww==?iv=QFYUrl5or/n/qamY79ze0A==")
+	if err != nil {
+		t.Fatalf("Decrypt() failed: %s", err)
+	}
+	if plaintext != "hello" {
+		t.Errorf("Decrypt() returned %q", plaintext)
+	}
+
+	key, err := nostr.ParsePrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := nostr.ParsePublicKey(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := key.SharedSecret(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err = DecryptWithKey(secret, "A+fRnU4aXS4kbTLfowqAww==?iv=QFYUrl5or/n/qamY79ze0A==")
+	if err != nil {
+		t.Fatalf("DecryptWithKey() failed: %s", err)
+	}
+	if plaintext != "hello" {
+		t.Errorf("DecryptWithKey() returned %q", plaintext)
+	}
+}
+
+func TestDecryptInvalidContent(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	_, bobPub := newKeyPair(t)
+
+	for _, content := range []string{
+		"",
+		"A+fRnU4aXS4kbTLfowqAww==",
+		"A+fRnU4aXS4kbTLfowqAww==?iv=invalid",
+		"A+fRnU4aXS4k?iv=QFYUrl5or/n/qamY79ze0A==",
+	} {
+		if _, err := Decrypt(alicePriv, bobPub, content); err == nil {
+			t.Errorf("Decrypt(%q) succeeded unexpectedly", content)
+		}
+	}
+}
+
+func TestDirectMessage(t *testing.T) {
+	alicePriv, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+	carolPriv, _ := newKeyPair(t)
+
+	event, err := NewDirectMessage(alicePriv, bobPub, "hello bob")
+	if err != nil {
+		t.Fatalf("NewDirectMessage() failed: %s", err)
+	}
+	if event.Kind != nostr.EventKindEncryptedDirectMessages || event.PubKey != alicePub {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if err := event.Verify(); err != nil {
+		t.Errorf("event.Verify() failed: %s", err)
+	}
+
+	for _, privKey := range []string{bobPriv, alicePriv} {
+		plaintext, err := DecryptDirectMessage(privKey, event)
+		if err != nil {
+			t.Fatalf("DecryptDirectMessage() failed: %s", err)
+		}
+		if plaintext != "hello bob" {
+			t.Errorf("DecryptDirectMessage() returned %q", plaintext)
+		}
+	}
+
+	if _, err := DecryptDirectMessage(carolPriv, event); err == nil {
+		t.Error("DecryptDirectMessage() succeeded for a third party")
+	}
+}
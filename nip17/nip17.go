@@ -0,0 +1,68 @@
+// Package nip17 implements private direct messages defined in NIP-17.
+//
+// Messages are unsigned kind 14 events gift wrapped with NIP-59
+// for each recipient and the sender,
+// so that relays can see neither the sender nor the content.
+package nip17
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip59"
+)
+
+// NewDirectMessage creates a private direct message from the owner of privKey
+// to the owners of recipientPubKeys.
+// It returns gift wraps keyed by the public key they are addressed to.
+// A gift wrap is also addressed to the sender so that the message can be read from other devices.
+func NewDirectMessage(privKey string, recipientPubKeys []string, content string) (map[string]*nostr.Event, error) {
+	if len(recipientPubKeys) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]nostr.Tag, 0, len(recipientPubKeys))
+	for _, recipient := range recipientPubKeys {
+		tags = append(tags, nostr.Tag{"p", recipient})
+	}
+	rumor := &nostr.Event{
+		PubKey:    pubKey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindPrivateDirectMessage,
+		Tags:      tags,
+		Content:   content,
+	}
+
+	wraps := make(map[string]*nostr.Event, len(recipientPubKeys)+1)
+	for _, receiver := range append([]string{pubKey}, recipientPubKeys...) {
+		if _, ok := wraps[receiver]; ok {
+			continue
+		}
+		wrap, err := nip59.GiftWrap(privKey, rumor, receiver)
+		if err != nil {
+			return nil, err
+		}
+		wraps[receiver] = wrap
+	}
+	return wraps, nil
+}
+
+// DecryptDirectMessage unwraps a gift wrap addressed to the owner of privKey
+// and returns the private direct message in it.
+// The PubKey field of the message is the verified sender.
+func DecryptDirectMessage(privKey string, wrap *nostr.Event) (*nostr.Event, error) {
+	rumor, err := nip59.Unwrap(privKey, wrap)
+	if err != nil {
+		return nil, err
+	}
+	if rumor.Kind != nostr.EventKindPrivateDirectMessage {
+		return nil, fmt.Errorf("unexpected event kind: %d", rumor.Kind)
+	}
+	return rumor, nil
+}
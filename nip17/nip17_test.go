@@ -0,0 +1,75 @@
+package nip17
+
+import (
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+)
+
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, pubKey
+}
+
+func TestDirectMessage(t *testing.T) {
+	alicePriv, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+	carolPriv, carolPub := newKeyPair(t)
+
+	wraps, err := NewDirectMessage(alicePriv, []string{bobPub, carolPub}, "hello")
+	if err != nil {
+		t.Fatalf("NewDirectMessage() failed: %s", err)
+	}
+	if len(wraps) != 3 {
+		t.Fatalf("unexpected number of gift wraps: %d", len(wraps))
+	}
+
+	for _, tc := range []struct {
+		privKey string
+		pubKey  string
+	}{
+		{alicePriv, alicePub},
+		{bobPriv, bobPub},
+		{carolPriv, carolPub},
+	} {
+		wrap, ok := wraps[tc.pubKey]
+		if !ok {
+			t.Fatalf("missing gift wrap for %s", tc.pubKey)
+		}
+		message, err := DecryptDirectMessage(tc.privKey, wrap)
+		if err != nil {
+			t.Fatalf("DecryptDirectMessage() failed: %s", err)
+		}
+		if message.PubKey != alicePub {
+			t.Errorf("unexpected sender: %s", message.PubKey)
+		}
+		if message.Content != "hello" {
+			t.Errorf("unexpected content: %s", message.Content)
+		}
+		if len(message.Tags) != 2 {
+			t.Errorf("unexpected tags: %v", message.Tags)
+		}
+	}
+}
+
+func TestDecryptDirectMessageWrongRecipient(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	_, bobPub := newKeyPair(t)
+	malloryPriv, _ := newKeyPair(t)
+
+	wraps, err := NewDirectMessage(alicePriv, []string{bobPub}, "hello")
+	if err != nil {
+		t.Fatalf("NewDirectMessage() failed: %s", err)
+	}
+	if _, err := DecryptDirectMessage(malloryPriv, wraps[bobPub]); err == nil {
+		t.Errorf("DecryptDirectMessage() succeeded with wrong key")
+	}
+}
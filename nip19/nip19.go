@@ -0,0 +1,354 @@
+// Package nip19 implements bech32-encoded entities defined in NIP-19.
+//
+// Keys and event IDs are encoded as npub, nsec and note.
+// Pointers with additional metadata such as relay hints are encoded
+// as nprofile, nevent and naddr using TLV (type-length-value).
+package nip19
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/shota3506/go-nostr"
+)
+
+const (
+	PrefixPublicKey  = "npub"
+	PrefixPrivateKey = "nsec"
+	PrefixNote       = "note"
+	PrefixProfile    = "nprofile"
+	PrefixEvent      = "nevent"
+	PrefixEntity     = "naddr"
+)
+
+// TLV types
+const (
+	tlvSpecial byte = 0
+	tlvRelay   byte = 1
+	tlvAuthor  byte = 2
+	tlvKind    byte = 3
+)
+
+// A ProfilePointer points to a profile.
+// It's encoded as nprofile.
+type ProfilePointer struct {
+	PublicKey string
+	Relays    []string
+}
+
+// An EventPointer points to an event.
+// It's encoded as nevent.
+type EventPointer struct {
+	ID     string
+	Relays []string
+	Author string          // optional
+	Kind   nostr.EventKind // optional, omitted if zero
+}
+
+// An EntityPointer points to an addressable event.
+// It's encoded as naddr.
+type EntityPointer struct {
+	PublicKey  string
+	Kind       nostr.EventKind
+	Identifier string // the "d" tag value
+	Relays     []string
+}
+
+// EncodePublicKey encodes a hex public key as npub.
+func EncodePublicKey(pubKey string) (string, error) {
+	return encodeHex(PrefixPublicKey, pubKey)
+}
+
+// EncodePrivateKey encodes a hex private key as nsec.
+func EncodePrivateKey(privKey string) (string, error) {
+	return encodeHex(PrefixPrivateKey, privKey)
+}
+
+// EncodeNote encodes a hex event ID as note.
+func EncodeNote(id string) (string, error) {
+	return encodeHex(PrefixNote, id)
+}
+
+// EncodeProfile encodes a profile pointer as nprofile.
+func EncodeProfile(p ProfilePointer) (string, error) {
+	pubKey, err := decodeHex32(p.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	var tlv tlvEncoder
+	tlv.add(tlvSpecial, pubKey)
+	tlv.addRelays(p.Relays)
+	return tlv.encode(PrefixProfile)
+}
+
+// EncodeEvent encodes an event pointer as nevent.
+func EncodeEvent(p EventPointer) (string, error) {
+	id, err := decodeHex32(p.ID)
+	if err != nil {
+		return "", fmt.Errorf("invalid event id: %w", err)
+	}
+
+	var tlv tlvEncoder
+	tlv.add(tlvSpecial, id)
+	tlv.addRelays(p.Relays)
+	if p.Author != "" {
+		author, err := decodeHex32(p.Author)
+		if err != nil {
+			return "", fmt.Errorf("invalid author: %w", err)
+		}
+		tlv.add(tlvAuthor, author)
+	}
+	if p.Kind != 0 {
+		tlv.add(tlvKind, binary.BigEndian.AppendUint32(nil, uint32(p.Kind)))
+	}
+	return tlv.encode(PrefixEvent)
+}
+
+// EncodeEntity encodes an entity pointer as naddr.
+func EncodeEntity(p EntityPointer) (string, error) {
+	pubKey, err := decodeHex32(p.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+
+	var tlv tlvEncoder
+	tlv.add(tlvSpecial, []byte(p.Identifier))
+	tlv.addRelays(p.Relays)
+	tlv.add(tlvAuthor, pubKey)
+	tlv.add(tlvKind, binary.BigEndian.AppendUint32(nil, uint32(p.Kind)))
+	return tlv.encode(PrefixEntity)
+}
+
+// Decode decodes a NIP-19 entity.
+// It returns the prefix and the value, whose type depends on the prefix:
+// a hex string for npub, nsec and note,
+// ProfilePointer for nprofile, EventPointer for nevent and EntityPointer for naddr.
+func Decode(s string) (string, any, error) {
+	prefix, data, err := decodeBech32(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch prefix {
+	case PrefixPublicKey, PrefixPrivateKey, PrefixNote:
+		if len(data) != 32 {
+			return "", nil, fmt.Errorf("invalid %s length: %d", prefix, len(data))
+		}
+		return prefix, hex.EncodeToString(data), nil
+	case PrefixProfile:
+		p, err := decodeProfile(data)
+		if err != nil {
+			return "", nil, err
+		}
+		return prefix, p, nil
+	case PrefixEvent:
+		p, err := decodeEvent(data)
+		if err != nil {
+			return "", nil, err
+		}
+		return prefix, p, nil
+	case PrefixEntity:
+		p, err := decodeEntity(data)
+		if err != nil {
+			return "", nil, err
+		}
+		return prefix, p, nil
+	}
+	return "", nil, fmt.Errorf("unknown prefix: %s", prefix)
+}
+
+// DecodePublicKey decodes npub into a hex public key.
+func DecodePublicKey(s string) (string, error) {
+	return decodeHex(PrefixPublicKey, s)
+}
+
+// DecodePrivateKey decodes nsec into a hex private key.
+func DecodePrivateKey(s string) (string, error) {
+	return decodeHex(PrefixPrivateKey, s)
+}
+
+// DecodeNote decodes note into a hex event ID.
+func DecodeNote(s string) (string, error) {
+	return decodeHex(PrefixNote, s)
+}
+
+func encodeHex(prefix, s string) (string, error) {
+	b, err := decodeHex32(s)
+	if err != nil {
+		return "", err
+	}
+	return encodeBech32(prefix, b)
+}
+
+func decodeHex(prefix, s string) (string, error) {
+	p, value, err := Decode(s)
+	if err != nil {
+		return "", err
+	}
+	if p != prefix {
+		return "", fmt.Errorf("unexpected prefix: %s", p)
+	}
+	return value.(string), nil
+}
+
+func decodeHex32(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 32 {
+		return nil, fmt.Errorf("invalid length: %d", len(b))
+	}
+	return b, nil
+}
+
+func decodeProfile(data []byte) (ProfilePointer, error) {
+	var p ProfilePointer
+	err := parseTLV(data, func(typ byte, value []byte) error {
+		switch typ {
+		case tlvSpecial:
+			if len(value) != 32 {
+				return fmt.Errorf("invalid public key length: %d", len(value))
+			}
+			p.PublicKey = hex.EncodeToString(value)
+		case tlvRelay:
+			p.Relays = append(p.Relays, string(value))
+		}
+		return nil
+	})
+	if err != nil {
+		return ProfilePointer{}, err
+	}
+	if p.PublicKey == "" {
+		return ProfilePointer{}, errors.New("missing public key")
+	}
+	return p, nil
+}
+
+func decodeEvent(data []byte) (EventPointer, error) {
+	var p EventPointer
+	err := parseTLV(data, func(typ byte, value []byte) error {
+		switch typ {
+		case tlvSpecial:
+			if len(value) != 32 {
+				return fmt.Errorf("invalid event id length: %d", len(value))
+			}
+			p.ID = hex.EncodeToString(value)
+		case tlvRelay:
+			p.Relays = append(p.Relays, string(value))
+		case tlvAuthor:
+			if len(value) != 32 {
+				return fmt.Errorf("invalid author length: %d", len(value))
+			}
+			p.Author = hex.EncodeToString(value)
+		case tlvKind:
+			if len(value) != 4 {
+				return fmt.Errorf("invalid kind length: %d", len(value))
+			}
+			p.Kind = nostr.EventKind(binary.BigEndian.Uint32(value))
+		}
+		return nil
+	})
+	if err != nil {
+		return EventPointer{}, err
+	}
+	if p.ID == "" {
+		return EventPointer{}, errors.New("missing event id")
+	}
+	return p, nil
+}
+
+func decodeEntity(data []byte) (EntityPointer, error) {
+	var (
+		p                                 EntityPointer
+		hasIdentifier, hasAuthor, hasKind bool
+	)
+	err := parseTLV(data, func(typ byte, value []byte) error {
+		switch typ {
+		case tlvSpecial:
+			p.Identifier = string(value)
+			hasIdentifier = true
+		case tlvRelay:
+			p.Relays = append(p.Relays, string(value))
+		case tlvAuthor:
+			if len(value) != 32 {
+				return fmt.Errorf("invalid author length: %d", len(value))
+			}
+			p.PublicKey = hex.EncodeToString(value)
+			hasAuthor = true
+		case tlvKind:
+			if len(value) != 4 {
+				return fmt.Errorf("invalid kind length: %d", len(value))
+			}
+			p.Kind = nostr.EventKind(binary.BigEndian.Uint32(value))
+			hasKind = true
+		}
+		return nil
+	})
+	if err != nil {
+		return EntityPointer{}, err
+	}
+	switch {
+	case !hasIdentifier:
+		return EntityPointer{}, errors.New("missing identifier")
+	case !hasAuthor:
+		return EntityPointer{}, errors.New("missing author")
+	case !hasKind:
+		return EntityPointer{}, errors.New("missing kind")
+	}
+	return p, nil
+}
+
+// tlvEncoder builds TLV data.
+// The first error is kept and returned by encode.
+type tlvEncoder struct {
+	buf []byte
+	err error
+}
+
+func (e *tlvEncoder) add(typ byte, value []byte) {
+	if e.err != nil {
+		return
+	}
+	if len(value) > 255 {
+		e.err = fmt.Errorf("tlv value too long: %d", len(value))
+		return
+	}
+	e.buf = append(e.buf, typ, byte(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+func (e *tlvEncoder) addRelays(relays []string) {
+	for _, relay := range relays {
+		e.add(tlvRelay, []byte(relay))
+	}
+}
+
+func (e *tlvEncoder) encode(prefix string) (string, error) {
+	if e.err != nil {
+		return "", e.err
+	}
+	return encodeBech32(prefix, e.buf)
+}
+
+// parseTLV calls f for each entry of data.
+// Unknown types are passed to f as well and expected to be ignored.
+func parseTLV(data []byte, f func(typ byte, value []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return errors.New("invalid tlv: truncated header")
+		}
+		typ, n := data[0], int(data[1])
+		if len(data) < 2+n {
+			return errors.New("invalid tlv: truncated value")
+		}
+		if err := f(typ, data[2:2+n]); err != nil {
+			return err
+		}
+		data = data[2+n:]
+	}
+	return nil
+}
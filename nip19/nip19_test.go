@@ -0,0 +1,232 @@
+package nip19
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+)
+
+const (
+	testPubKey = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	testID     = "b9f5441e45ca39179320e0031cfb18e34078673dcc3d3e3a3b3a981760aa5696"
+)
+
+// Test vectors are taken from NIP-19.
+
+func TestEncodeDecodeHex(t *testing.T) {
+	for _, tc := range []struct {
+		prefix      string
+		hex         string
+		bech32      string
+		encode      func(string) (string, error)
+		decode      func(string) (string, error)
+		wrongDecode func(string) (string, error)
+	}{
+		{
+			prefix:      PrefixPublicKey,
+			hex:         "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e",
+			bech32:      "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg",
+			encode:      EncodePublicKey,
+			decode:      DecodePublicKey,
+			wrongDecode: DecodePrivateKey,
+		},
+		{
+			prefix:      PrefixPrivateKey,
+			hex:         "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa",
+			bech32:      "nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5",
+			encode:      EncodePrivateKey,
+			decode:      DecodePrivateKey,
+			wrongDecode: DecodeNote,
+		},
+	} {
+		encoded, err := tc.encode(tc.hex)
+		if err != nil {
+			t.Fatalf("encode failed: %s", err)
+		}
+		if encoded != tc.bech32 {
+			t.Errorf("encode returned %s, expected %s", encoded, tc.bech32)
+		}
+
+		decoded, err := tc.decode(tc.bech32)
+		if err != nil {
+			t.Fatalf("decode failed: %s", err)
+		}
+		if decoded != tc.hex {
+			t.Errorf("decode returned %s, expected %s", decoded, tc.hex)
+		}
+
+		prefix, value, err := Decode(strings.ToUpper(tc.bech32))
+		if err != nil {
+			t.Fatalf("Decode() failed: %s", err)
+		}
+		if prefix != tc.prefix || value != tc.hex {
+			t.Errorf("Decode() returned %s %v", prefix, value)
+		}
+
+		if _, err := tc.wrongDecode(tc.bech32); err == nil {
+			t.Errorf("decode with wrong prefix succeeded")
+		}
+	}
+}
+
+func TestEncodeNote(t *testing.T) {
+	encoded, err := EncodeNote(testID)
+	if err != nil {
+		t.Fatalf("EncodeNote() failed: %s", err)
+	}
+	if !strings.HasPrefix(encoded, "note1") {
+		t.Errorf("unexpected note: %s", encoded)
+	}
+	decoded, err := DecodeNote(encoded)
+	if err != nil {
+		t.Fatalf("DecodeNote() failed: %s", err)
+	}
+	if decoded != testID {
+		t.Errorf("DecodeNote() returned %s, expected %s", decoded, testID)
+	}
+}
+
+func TestDecodeProfile(t *testing.T) {
+	prefix, value, err := Decode("nprofile1qqsrhuxx8l9ex335q7he0f09aej04zpazpl0ne2cgukyawd24mayt8gpp4mhxue69uhhytnc9e3k7mgpz4mhxue69uhkg6nzv9ejuumpv34kytnrdaksjlyr9p")
+	if err != nil {
+		t.Fatalf("Decode() failed: %s", err)
+	}
+	if prefix != PrefixProfile {
+		t.Errorf("unexpected prefix: %s", prefix)
+	}
+	expected := ProfilePointer{
+		PublicKey: "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",
+		Relays:    []string{"wss://r.x.com", "wss://djbas.sadkb.com"},
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Decode() returned %+v, expected %+v", value, expected)
+	}
+
+	encoded, err := EncodeProfile(expected)
+	if err != nil {
+		t.Fatalf("EncodeProfile() failed: %s", err)
+	}
+	if encoded != "nprofile1qqsrhuxx8l9ex335q7he0f09aej04zpazpl0ne2cgukyawd24mayt8gpp4mhxue69uhhytnc9e3k7mgpz4mhxue69uhkg6nzv9ejuumpv34kytnrdaksjlyr9p" {
+		t.Errorf("EncodeProfile() returned %s", encoded)
+	}
+}
+
+func TestEncodeDecodePointers(t *testing.T) {
+	longRelay := "wss://" + strings.Repeat("a", 100) + ".example.com"
+	for _, tc := range []struct {
+		name   string
+		prefix string
+		value  any
+	}{
+		{
+			name:   "event",
+			prefix: PrefixEvent,
+			value: EventPointer{
+				ID:     testID,
+				Relays: []string{"wss://relay.example.com", longRelay},
+				Author: testPubKey,
+				Kind:   nostr.EventKindTextNote,
+			},
+		},
+		{
+			name:   "event without optional fields",
+			prefix: PrefixEvent,
+			value:  EventPointer{ID: testID},
+		},
+		{
+			name:   "entity",
+			prefix: PrefixEntity,
+			value: EntityPointer{
+				PublicKey:  testPubKey,
+				Kind:       30023,
+				Identifier: "my-article",
+				Relays:     []string{"wss://relay.example.com"},
+			},
+		},
+		{
+			name:   "entity with empty identifier",
+			prefix: PrefixEntity,
+			value: EntityPointer{
+				PublicKey: testPubKey,
+				Kind:      10002,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				encoded string
+				err     error
+			)
+			switch v := tc.value.(type) {
+			case EventPointer:
+				encoded, err = EncodeEvent(v)
+			case EntityPointer:
+				encoded, err = EncodeEntity(v)
+			}
+			if err != nil {
+				t.Fatalf("encode failed: %s", err)
+			}
+			if !strings.HasPrefix(encoded, tc.prefix+"1") {
+				t.Errorf("unexpected encoding: %s", encoded)
+			}
+
+			prefix, value, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() failed: %s", err)
+			}
+			if prefix != tc.prefix {
+				t.Errorf("unexpected prefix: %s", prefix)
+			}
+			if !reflect.DeepEqual(value, tc.value) {
+				t.Errorf("Decode() returned %+v, expected %+v", value, tc.value)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	npub := "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg"
+	for _, tc := range []struct {
+		name string
+		s    string
+	}{
+		{name: "empty", s: ""},
+		{name: "invalid checksum", s: npub[:len(npub)-1] + "q"},
+		{name: "mixed case", s: "N" + npub[1:]},
+		{name: "invalid character", s: npub[:10] + "b" + npub[11:]},
+		{name: "unknown prefix", s: mustEncode(t, "nfoo", make([]byte, 32))},
+		{name: "invalid key length", s: mustEncode(t, PrefixPublicKey, make([]byte, 31))},
+		{name: "missing special", s: mustEncode(t, PrefixProfile, []byte{1, 1, 'a'})},
+		{name: "truncated tlv", s: mustEncode(t, PrefixEvent, []byte{0, 32, 1})},
+		{name: "missing kind", s: mustEncode(t, PrefixEntity, append([]byte{0, 0, 2, 32}, make([]byte, 32)...))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := Decode(tc.s); err == nil {
+				t.Errorf("Decode() succeeded")
+			}
+		})
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	if _, err := EncodePublicKey("abcd"); err == nil {
+		t.Errorf("EncodePublicKey() succeeded with short key")
+	}
+	if _, err := EncodeEvent(EventPointer{ID: testID, Author: "xyz"}); err == nil {
+		t.Errorf("EncodeEvent() succeeded with invalid author")
+	}
+	if _, err := EncodeProfile(ProfilePointer{PublicKey: testPubKey, Relays: []string{strings.Repeat("a", 256)}}); err == nil {
+		t.Errorf("EncodeProfile() succeeded with too long relay")
+	}
+}
+
+func mustEncode(t *testing.T, hrp string, data []byte) string {
+	t.Helper()
+	s, err := encodeBech32(hrp, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+// Package nip21 implements nostr: URIs defined in NIP-21
+// and extraction of references from event content.
+package nip21
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip19"
+)
+
+// Scheme is the URI scheme of Nostr entities.
+const Scheme = "nostr:"
+
+var (
+	uriPattern     = regexp.MustCompile(`(?i)nostr:(?:npub|note|nprofile|nevent|naddr)1[023456789acdefghjklmnpqrstuvwxyz]+`)
+	mentionPattern = regexp.MustCompile(`#\[(\d+)\]`)
+)
+
+// Encode returns the nostr: URI of a NIP-19 entity.
+func Encode(entity string) string {
+	return Scheme + entity
+}
+
+// Parse decodes a nostr: URI.
+// It returns the NIP-19 prefix and the decoded value as nip19.Decode does.
+// Private keys are rejected because they must not be shared as URIs.
+func Parse(uri string) (string, any, error) {
+	if len(uri) < len(Scheme) || !strings.EqualFold(uri[:len(Scheme)], Scheme) {
+		return "", nil, errors.New("missing nostr scheme")
+	}
+	prefix, value, err := nip19.Decode(uri[len(Scheme):])
+	if err != nil {
+		return "", nil, err
+	}
+	if prefix == nip19.PrefixPrivateKey {
+		return "", nil, fmt.Errorf("unsupported prefix: %s", prefix)
+	}
+	return prefix, value, nil
+}
+
+// A Reference is a reference to another entity found in content.
+// It's either a nostr: URI or a legacy "#[n]" mention of the n-th tag.
+type Reference struct {
+	// Start and End are the byte offsets of Text in content.
+	Start int
+	End   int
+	Text  string
+
+	// Prefix and Value are the NIP-19 prefix and the decoded value as nip19.Decode returns.
+	// They are empty for legacy mentions that cannot be resolved.
+	Prefix string
+	Value  any
+
+	// Index is the tag index of a legacy mention, or -1 for nostr: URIs.
+	Index int
+	// Tag is the tag referenced by a legacy mention if it exists.
+	Tag nostr.Tag
+}
+
+// FindReferences returns the references in content ordered by position.
+// nostr: URIs that cannot be decoded are skipped.
+// Legacy mentions are returned unresolved.
+func FindReferences(content string) []Reference {
+	var refs []Reference
+	for _, loc := range uriPattern.FindAllStringIndex(content, -1) {
+		text := content[loc[0]:loc[1]]
+		prefix, value, err := Parse(text)
+		if err != nil {
+			continue
+		}
+		refs = append(refs, Reference{
+			Start:  loc[0],
+			End:    loc[1],
+			Text:   text,
+			Prefix: prefix,
+			Value:  value,
+			Index:  -1,
+		})
+	}
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		index, err := strconv.Atoi(content[loc[2]:loc[3]])
+		if err != nil {
+			continue
+		}
+		refs = append(refs, Reference{
+			Start: loc[0],
+			End:   loc[1],
+			Text:  content[loc[0]:loc[1]],
+			Index: index,
+		})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Start < refs[j].Start })
+	return refs
+}
+
+// ParseReferences returns the references in the content of event ordered by position.
+// Legacy mentions are resolved with the tags of event:
+// "p" tags as public keys, "e" tags as notes and "a" tags as entities.
+func ParseReferences(event *nostr.Event) []Reference {
+	refs := FindReferences(event.Content)
+	for i := range refs {
+		ref := &refs[i]
+		if ref.Index < 0 || ref.Index >= len(event.Tags) {
+			continue
+		}
+		ref.Tag = event.Tags[ref.Index]
+		ref.Prefix, ref.Value = resolveTag(ref.Tag)
+	}
+	return refs
+}
+
+func resolveTag(tag nostr.Tag) (string, any) {
+	if len(tag) < 2 {
+		return "", nil
+	}
+	switch tag[0] {
+	case "p":
+		return nip19.PrefixPublicKey, tag[1]
+	case "e":
+		return nip19.PrefixNote, tag[1]
+	case "a":
+		p, ok := parseAddress(tag[1])
+		if !ok {
+			return "", nil
+		}
+		if len(tag) >= 3 && tag[2] != "" {
+			p.Relays = []string{tag[2]}
+		}
+		return nip19.PrefixEntity, p
+	}
+	return "", nil
+}
+
+// parseAddress parses an address in the form of "<kind>:<pubkey>:<d tag value>".
+func parseAddress(address string) (nip19.EntityPointer, bool) {
+	parts := strings.SplitN(address, ":", 3)
+	if len(parts) != 3 {
+		return nip19.EntityPointer{}, false
+	}
+	kind, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nip19.EntityPointer{}, false
+	}
+	return nip19.EntityPointer{
+		PublicKey:  parts[1],
+		Kind:       nostr.EventKind(kind),
+		Identifier: parts[2],
+	}, true
+}
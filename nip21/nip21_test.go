@@ -0,0 +1,122 @@
+package nip21
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip19"
+)
+
+const (
+	testNpub   = "npub10elfcs4fr0l0r8af98jlmgdh9c8tcxjvz9qkw038js35mp4dma8qzvjptg"
+	testPubKey = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	testID     = "b9f5441e45ca39179320e0031cfb18e34078673dcc3d3e3a3b3a981760aa5696"
+)
+
+func TestParse(t *testing.T) {
+	prefix, value, err := Parse(Encode(testNpub))
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+	if prefix != nip19.PrefixPublicKey || value != testPubKey {
+		t.Errorf("Parse() returned %s %v", prefix, value)
+	}
+
+	nsec, err := nip19.EncodePrivateKey(testPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uri := range []string{
+		testNpub,
+		"nostr:",
+		"nostr:" + nsec,
+		"nostr:npub1invalid",
+	} {
+		if _, _, err := Parse(uri); err == nil {
+			t.Errorf("Parse(%q) succeeded", uri)
+		}
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	nevent, err := nip19.EncodeEvent(nip19.EventPointer{ID: testID, Relays: []string{"wss://relay.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsec, err := nip19.EncodePrivateKey(testPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "hi nostr:" + testNpub + ", see nostr:" + nevent + ".\n" +
+		"ignored: nostr:" + nsec + " nostr:npub1broken #[x] " +
+		"legacy #[1]"
+	refs := FindReferences(content)
+	if len(refs) != 3 {
+		t.Fatalf("FindReferences() returned %d references: %+v", len(refs), refs)
+	}
+
+	for _, ref := range refs {
+		if content[ref.Start:ref.End] != ref.Text {
+			t.Errorf("unexpected position of %q: %d-%d", ref.Text, ref.Start, ref.End)
+		}
+	}
+
+	if refs[0].Text != "nostr:"+testNpub || refs[0].Prefix != nip19.PrefixPublicKey || refs[0].Value != testPubKey || refs[0].Index != -1 {
+		t.Errorf("unexpected reference: %+v", refs[0])
+	}
+	expected := nip19.EventPointer{ID: testID, Relays: []string{"wss://relay.example.com"}}
+	if refs[1].Prefix != nip19.PrefixEvent || !reflect.DeepEqual(refs[1].Value, expected) {
+		t.Errorf("unexpected reference: %+v", refs[1])
+	}
+	if refs[2].Text != "#[1]" || refs[2].Index != 1 || refs[2].Prefix != "" || refs[2].Tag != nil {
+		t.Errorf("unexpected reference: %+v", refs[2])
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	event := &nostr.Event{
+		Kind: nostr.EventKindTextNote,
+		Tags: []nostr.Tag{
+			{"p", testPubKey},
+			{"e", testID},
+			{"a", "30023:" + testPubKey + ":my-article", "wss://relay.example.com"},
+			{"t", "nostr"},
+		},
+		Content: "#[0] #[1] #[2] #[3] #[4]",
+	}
+
+	refs := ParseReferences(event)
+	if len(refs) != 5 {
+		t.Fatalf("ParseReferences() returned %d references", len(refs))
+	}
+	for i, tc := range []struct {
+		prefix string
+		value  any
+	}{
+		{nip19.PrefixPublicKey, testPubKey},
+		{nip19.PrefixNote, testID},
+		{nip19.PrefixEntity, nip19.EntityPointer{
+			PublicKey:  testPubKey,
+			Kind:       30023,
+			Identifier: "my-article",
+			Relays:     []string{"wss://relay.example.com"},
+		}},
+		{"", nil},
+		{"", nil},
+	} {
+		if refs[i].Index != i {
+			t.Errorf("unexpected index: %d", refs[i].Index)
+		}
+		if refs[i].Prefix != tc.prefix || !reflect.DeepEqual(refs[i].Value, tc.value) {
+			t.Errorf("unexpected reference %d: %+v", i, refs[i])
+		}
+	}
+	if !reflect.DeepEqual(refs[3].Tag, event.Tags[3]) {
+		t.Errorf("unexpected tag: %v", refs[3].Tag)
+	}
+	if refs[4].Tag != nil {
+		t.Errorf("unexpected tag: %v", refs[4].Tag)
+	}
+}
@@ -0,0 +1,233 @@
+// Package nip44 implements versioned encrypted payloads defined in NIP-44.
+//
+// Only version 2 is supported.
+package nip44
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/shota3506/go-nostr"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	version = 2
+
+	minPlaintextSize = 1
+	maxPlaintextSize = 65535
+
+	minPayloadSize = 132
+	maxPayloadSize = 87472
+	minDataSize    = 99
+	maxDataSize    = 65603
+)
+
+// Encrypt encrypts plaintext with the conversation key between privKey and pubKey.
+// The result is a base64 encoded payload.
+func Encrypt(privKey, pubKey, plaintext string) (string, error) {
+	key, err := ConversationKey(privKey, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return EncryptWithKey(key, plaintext)
+}
+
+// Decrypt decrypts payload encrypted by Encrypt with the conversation key between privKey and pubKey.
+func Decrypt(privKey, pubKey, payload string) (string, error) {
+	key, err := ConversationKey(privKey, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return DecryptWithKey(key, payload)
+}
+
+// ConversationKey returns the conversation key between privKey and pubKey.
+// The key is the same in both directions,
+// so it can be computed once and reused for every message between two parties.
+func ConversationKey(privKey, pubKey string) ([]byte, error) {
+	sk, err := nostr.ParsePrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Zero()
+	pk, err := nostr.ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveConversationKey(&sk, pk)
+}
+
+// DeriveConversationKey returns the conversation key between key and pubKey.
+// Unlike ConversationKey, it does not copy the private key into a string.
+func DeriveConversationKey(key *nostr.PrivateKey, pubKey nostr.PublicKey) ([]byte, error) {
+	shared, err := key.SharedSecret(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(shared)
+	return hkdf.Extract(sha256.New, shared, []byte("nip44-v2")), nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// EncryptWithKey encrypts plaintext with the conversation key.
+func EncryptWithKey(conversationKey []byte, plaintext string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return encrypt(conversationKey, nonce, plaintext)
+}
+
+// DecryptWithKey decrypts payload with the conversation key.
+func DecryptWithKey(conversationKey []byte, payload string) (string, error) {
+	if len(payload) == 0 || payload[0] == '#' {
+		return "", errors.New("unknown version")
+	}
+	if len(payload) < minPayloadSize || len(payload) > maxPayloadSize {
+		return "", fmt.Errorf("invalid payload length: %d", len(payload))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(data) < minDataSize || len(data) > maxDataSize {
+		return "", fmt.Errorf("invalid data length: %d", len(data))
+	}
+	if data[0] != version {
+		return "", fmt.Errorf("unknown version: %d", data[0])
+	}
+
+	nonce := data[1:33]
+	ciphertext := data[33 : len(data)-32]
+	mac := data[len(data)-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(mac, computeMAC(hmacKey, nonce, ciphertext)) {
+		return "", errors.New("invalid hmac")
+	}
+
+	padded := make([]byte, len(ciphertext))
+	if err := xorKeyStream(chachaKey, chachaNonce, padded, ciphertext); err != nil {
+		return "", err
+	}
+	plaintext, err := unpad(padded)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func encrypt(conversationKey, nonce []byte, plaintext string) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(padded))
+	if err := xorKeyStream(chachaKey, chachaNonce, ciphertext, padded); err != nil {
+		return "", err
+	}
+	mac := computeMAC(hmacKey, nonce, ciphertext)
+
+	data := make([]byte, 0, 1+len(nonce)+len(ciphertext)+len(mac))
+	data = append(data, version)
+	data = append(data, nonce...)
+	data = append(data, ciphertext...)
+	data = append(data, mac...)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// messageKeys derives the ChaCha20 key, the ChaCha20 nonce and the HMAC key
+// from the conversation key and the nonce of a message.
+func messageKeys(conversationKey, nonce []byte) ([]byte, []byte, []byte, error) {
+	if len(conversationKey) != 32 {
+		return nil, nil, nil, fmt.Errorf("invalid conversation key length: %d", len(conversationKey))
+	}
+	if len(nonce) != 32 {
+		return nil, nil, nil, fmt.Errorf("invalid nonce length: %d", len(nonce))
+	}
+
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, err
+	}
+	return keys[0:32], keys[32:44], keys[44:76], nil
+}
+
+func xorKeyStream(key, nonce, dst, src []byte) error {
+	c, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return err
+	}
+	c.XORKeyStream(dst, src)
+	return nil
+}
+
+func computeMAC(key, nonce, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// paddedLen returns the length of plaintext of n bytes after padding.
+func paddedLen(n int) int {
+	if n <= 32 {
+		return 32
+	}
+	next := 1
+	for next < n {
+		next <<= 1
+	}
+	chunk := 32
+	if next > 256 {
+		chunk = next / 8
+	}
+	return chunk * ((n-1)/chunk + 1)
+}
+
+// pad prefixes plaintext with its length as a big-endian uint16
+// and appends zeros up to the padded length.
+func pad(plaintext []byte) ([]byte, error) {
+	n := len(plaintext)
+	if n < minPlaintextSize || n > maxPlaintextSize {
+		return nil, fmt.Errorf("invalid plaintext length: %d", n)
+	}
+	padded := make([]byte, 2+paddedLen(n))
+	binary.BigEndian.PutUint16(padded, uint16(n))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+// unpad removes the length prefix and padding.
+func unpad(padded []byte) ([]byte, error) {
+	if len(padded) < 2 {
+		return nil, errors.New("invalid padding")
+	}
+	n := int(binary.BigEndian.Uint16(padded))
+	if n < minPlaintextSize || len(padded) != 2+paddedLen(n) {
+		return nil, errors.New("invalid padding")
+	}
+	return padded[2 : 2+n], nil
+}
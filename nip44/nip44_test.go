@@ -0,0 +1,422 @@
+package nip44
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+)
+
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, pubKey
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alicePriv, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+
+	for _, n := range []int{1, 31, 32, 33, 100, 1000, 65535} {
+		plaintext := strings.Repeat("a", n)
+		payload, err := Encrypt(alicePriv, bobPub, plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt() failed: %s", err)
+		}
+
+		decrypted, err := Decrypt(bobPriv, alicePub, payload)
+		if err != nil {
+			t.Fatalf("Decrypt() failed: %s", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt() returned unexpected plaintext of length %d, expected %d", len(decrypted), n)
+		}
+	}
+}
+
+func TestEncryptInvalidPlaintext(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	_, bobPub := newKeyPair(t)
+
+	for _, plaintext := range []string{"", strings.Repeat("a", 65536)} {
+		if _, err := Encrypt(alicePriv, bobPub, plaintext); err == nil {
+			t.Errorf("Encrypt() succeeded for plaintext of length %d", len(plaintext))
+		}
+	}
+}
+
+// Test vectors are taken from https://github.com/paulmillr/nip44.
+
+func TestConversationKey(t *testing.T) {
+	for _, tc := range []struct {
+		privKey         string
+		pubKey          string
+		conversationKey string
+	}{
+		{
+			privKey:         "315e59ff51cb9209768cf7da80791ddcaae56ac9775eb25b6dee1234bc5d2268",
+			pubKey:          "c2f9d9948dc8c7c38321e4b85c8558872eafa0641cd269db76848a6073e69133",
+			conversationKey: "3dfef0ce2a4d80a25e7a328accf73448ef67096f65f79588e358d9a0eb9013f1",
+		},
+		{
+			privKey:         "a1e37752c9fdc1273be53f68c5f74be7c8905728e8de75800b94262f9497c86e",
+			pubKey:          "03bb7947065dde12ba991ea045132581d0954f042c84e06d8c00066e23c1a800",
+			conversationKey: "4d14f36e81b8452128da64fe6f1eae873baae2f444b02c950b90e43553f2178b",
+		},
+		{
+			privKey:         "98a5902fd67518a0c900f0fb62158f278f94a21d6f9d33d30cd3091195500311",
+			pubKey:          "aae65c15f98e5e677b5050de82e3aba47a6fe49b3dab7863cf35d9478ba9f7d1",
+			conversationKey: "9c00b769d5f54d02bf175b7284a1cbd28b6911b06cda6666b2243561ac96bad7",
+		},
+		{
+			privKey:         "86ae5ac8034eb2542ce23ec2f84375655dab7f836836bbd3c54cefe9fdc9c19f",
+			pubKey:          "59f90272378089d73f1339710c02e2be6db584e9cdbe86eed3578f0c67c23585",
+			conversationKey: "19f934aafd3324e8415299b64df42049afaa051c71c98d0aa10e1081f2e3e2ba",
+		},
+	} {
+		key, err := ConversationKey(tc.privKey, tc.pubKey)
+		if err != nil {
+			t.Fatalf("ConversationKey() failed: %s", err)
+		}
+		if hex.EncodeToString(key) != tc.conversationKey {
+			t.Errorf("ConversationKey() returned %x, expected %s", key, tc.conversationKey)
+		}
+	}
+}
+
+func TestConversationKeyInvalidKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		privKey string
+		pubKey  string
+	}{
+		{
+			name:    "private key higher than curve order",
+			privKey: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			pubKey:  "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name:    "private key is zero",
+			privKey: "0000000000000000000000000000000000000000000000000000000000000000",
+			pubKey:  "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name:    "private key equal to curve order",
+			privKey: "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+			pubKey:  "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name:    "public key higher than field prime",
+			privKey: "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364139",
+			pubKey:  "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		},
+		{
+			name:    "public key not on curve",
+			privKey: "0000000000000000000000000000000000000000000000000000000000000002",
+			pubKey:  "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name:    "public key of order 3 on twist",
+			privKey: "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20",
+			pubKey:  "0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			name:    "public key of order 13 on twist",
+			privKey: "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20",
+			pubKey:  "eb1f7200aecaa86682376fb1c13cd12b732221e774f553b0a0857f88fa20f86d",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ConversationKey(tc.privKey, tc.pubKey); err == nil {
+				t.Errorf("ConversationKey() succeeded")
+			}
+		})
+	}
+}
+
+func TestMessageKeys(t *testing.T) {
+	conversationKey := decodeHex(t, "a1a3d60f3470a8612633924e91febf96dc5366ce130f658b1f0fc652c20b3b54")
+	for _, tc := range []struct {
+		nonce       string
+		chachaKey   string
+		chachaNonce string
+		hmacKey     string
+	}{
+		{
+			nonce:       "e1e6f880560d6d149ed83dcc7e5861ee62a5ee051f7fde9975fe5d25d2a02d72",
+			chachaKey:   "f145f3bed47cb70dbeaac07f3a3fe683e822b3715edb7c4fe310829014ce7d76",
+			chachaNonce: "c4ad129bb01180c0933a160c",
+			hmacKey:     "027c1db445f05e2eee864a0975b0ddef5b7110583c8c192de3732571ca5838c4",
+		},
+		{
+			nonce:       "e1d6d28c46de60168b43d79dacc519698512ec35e8ccb12640fc8e9f26121101",
+			chachaKey:   "e35b88f8d4a8f1606c5082f7a64b100e5d85fcdb2e62aeafbec03fb9e860ad92",
+			chachaNonce: "22925e920cee4a50a478be90",
+			hmacKey:     "46a7c55d4283cb0df1d5e29540be67abfe709e3b2e14b7bf9976e6df994ded30",
+		},
+		{
+			nonce:       "cfc13bef512ac9c15951ab00030dfaf2626fdca638dedb35f2993a9eeb85d650",
+			chachaKey:   "020783eb35fdf5b80ef8c75377f4e937efb26bcbad0e61b4190e39939860c4bf",
+			chachaNonce: "d3594987af769a52904656ac",
+			hmacKey:     "237ec0ccb6ebd53d179fa8fd319e092acff599ef174c1fdafd499ef2b8dee745",
+		},
+	} {
+		chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, decodeHex(t, tc.nonce))
+		if err != nil {
+			t.Fatalf("messageKeys() failed: %s", err)
+		}
+		if hex.EncodeToString(chachaKey) != tc.chachaKey {
+			t.Errorf("messageKeys() returned chacha key %x, expected %s", chachaKey, tc.chachaKey)
+		}
+		if hex.EncodeToString(chachaNonce) != tc.chachaNonce {
+			t.Errorf("messageKeys() returned chacha nonce %x, expected %s", chachaNonce, tc.chachaNonce)
+		}
+		if hex.EncodeToString(hmacKey) != tc.hmacKey {
+			t.Errorf("messageKeys() returned hmac key %x, expected %s", hmacKey, tc.hmacKey)
+		}
+	}
+}
+
+func TestPaddedLen(t *testing.T) {
+	for _, tc := range [][2]int{
+		{16, 32}, {32, 32}, {33, 64}, {37, 64}, {45, 64}, {49, 64}, {64, 64},
+		{65, 96}, {100, 128}, {111, 128}, {200, 224}, {250, 256}, {320, 320},
+		{383, 384}, {384, 384}, {400, 448}, {500, 512}, {512, 512}, {515, 640},
+		{700, 768}, {800, 896}, {900, 1024}, {1020, 1024}, {65536, 65536},
+	} {
+		if n := paddedLen(tc[0]); n != tc[1] {
+			t.Errorf("paddedLen(%d) returned %d, expected %d", tc[0], n, tc[1])
+		}
+	}
+}
+
+func TestEncryptVectors(t *testing.T) {
+	for _, tc := range []struct {
+		privKey1        string
+		privKey2        string
+		conversationKey string
+		nonce           string
+		plaintext       string
+		payload         string
+	}{
+		{
+			privKey1:        "0000000000000000000000000000000000000000000000000000000000000001",
+			privKey2:        "0000000000000000000000000000000000000000000000000000000000000002",
+			conversationKey: "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+			nonce:           "0000000000000000000000000000000000000000000000000000000000000001",
+			plaintext:       "a",
+			payload:         "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb",
+		},
+		{
+			privKey1:        "0000000000000000000000000000000000000000000000000000000000000002",
+			privKey2:        "0000000000000000000000000000000000000000000000000000000000000001",
+			conversationKey: "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+			nonce:           "f00000000000000000000000000000f00000000000000000000000000000000f",
+			plaintext:       "🍕🫃",
+			payload:         "AvAAAAAAAAAAAAAAAAAAAPAAAAAAAAAAAAAAAAAAAAAPSKSK6is9ngkX2+cSq85Th16oRTISAOfhStnixqZziKMDvB0QQzgFZdjLTPicCJaV8nDITO+QfaQ61+KbWQIOO2Yj",
+		},
+		{
+			privKey1:        "5c0c523f52a5b6fad39ed2403092df8cebc36318b39383bca6c00808626fab3a",
+			privKey2:        "4b22aa260e4acb7021e32f38a6cdf4b673c6a277755bfce287e370c924dc936d",
+			conversationKey: "3e2b52a63be47d34fe0a80e34e73d436d6963bc8f39827f327057a9986c20a45",
+			nonce:           "b635236c42db20f021bb8d1cdff5ca75dd1a0cc72ea742ad750f33010b24f73b",
+			plaintext:       "表ポあA鷗ŒéＢ逍Üßªąñ丂㐀𠀀",
+			payload:         "ArY1I2xC2yDwIbuNHN/1ynXdGgzHLqdCrXUPMwELJPc7s7JqlCMJBAIIjfkpHReBPXeoMCyuClwgbT419jUWU1PwaNl4FEQYKCDKVJz+97Mp3K+Q2YGa77B6gpxB/lr1QgoqpDf7wDVrDmOqGoiPjWDqy8KzLueKDcm9BVP8xeTJIxs=",
+		},
+		{
+			privKey1:        "8f40e50a84a7462e2b8d24c28898ef1f23359fff50d8c509e6fb7ce06e142f9c",
+			privKey2:        "b9b0a1e9cc20100c5faa3bbe2777303d25950616c4c6a3fa2e3e046f936ec2ba",
+			conversationKey: "d5a2f879123145a4b291d767428870f5a8d9e5007193321795b40183d4ab8c2b",
+			nonce:           "b20989adc3ddc41cd2c435952c0d59a91315d8c5218d5040573fc3749543acaf",
+			plaintext:       "ability🤝的 ȺȾ",
+			payload:         "ArIJia3D3cQc0sQ1lSwNWakTFdjFIY1QQFc/w3SVQ6yvbG2S0x4Yu86QGwPTy7mP3961I1XqB6SFFTzqDZZavhxoWMj7mEVGMQIsh2RLWI5EYQaQDIePSnXPlzf7CIt+voTD",
+		},
+		{
+			privKey1:        "eba1687cab6a3101bfc68fd70f214aa4cc059e9ec1b79fdb9ad0a0a4e259829f",
+			privKey2:        "dff20d262bef9dfd94666548f556393085e6ea421c8af86e9d333fa8747e94b3",
+			conversationKey: "4f1538411098cf11c8af216836444787c462d47f97287f46cf7edb2c4915b8a5",
+			nonce:           "2180b52ae645fcf9f5080d81b1f0b5d6f2cd77ff3c986882bb549158462f3407",
+			plaintext:       "( ͡° ͜ʖ ͡°)",
+			payload:         "AiGAtSrmRfz59QgNgbHwtdbyzXf/PJhogrtUkVhGLzQHv4qhKQwnFQ54OjVMgqCea/Vj0YqBSdhqNR777TJ4zIUk7R0fnizp6l1zwgzWv7+ee6u+0/89KIjY5q1wu6inyuiv",
+		},
+		{
+			privKey1:        "d5633530f5bcfebceb5584cfbbf718a30df0751b729dd9a789b9f30c0587d74e",
+			privKey2:        "b74e6a341fb134127272b795a08b59250e5fa45a82a2eb4095e4ce9ed5f5e214",
+			conversationKey: "75fe686d21a035f0c7cd70da64ba307936e5ca0b20710496a6b6b5f573377bdd",
+			nonce:           "4f1a31909f3483a9e69c8549a55bbc9af25fa5bbecf7bd32d9896f83ef2e12e0",
+			plaintext:       "𝖑𝖆𝖟𝖞 社會科學院語學研究所",
+			payload:         "Ak8aMZCfNIOp5pyFSaVbvJryX6W77Pe9MtmJb4PvLhLgh/TsxPLFSANcT67EC1t/qxjru5ZoADjKVEt2ejdx+xGvH49mcdfbc+l+L7gJtkH7GLKpE9pQNQWNHMAmj043PAXJZ++fiJObMRR2mye5VHEANzZWkZXMrXF7YjuG10S1pOU=",
+		},
+	} {
+		pubKey2, err := nostr.PublicKeyFromPrivateKey(tc.privKey2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ConversationKey(tc.privKey1, pubKey2)
+		if err != nil {
+			t.Fatalf("ConversationKey() failed: %s", err)
+		}
+		if hex.EncodeToString(key) != tc.conversationKey {
+			t.Errorf("ConversationKey() returned %x, expected %s", key, tc.conversationKey)
+		}
+
+		payload, err := encrypt(key, decodeHex(t, tc.nonce), tc.plaintext)
+		if err != nil {
+			t.Fatalf("encrypt() failed: %s", err)
+		}
+		if payload != tc.payload {
+			t.Errorf("encrypt() returned %s, expected %s", payload, tc.payload)
+		}
+
+		plaintext, err := DecryptWithKey(key, tc.payload)
+		if err != nil {
+			t.Fatalf("DecryptWithKey() failed: %s", err)
+		}
+		if plaintext != tc.plaintext {
+			t.Errorf("DecryptWithKey() returned %q, expected %q", plaintext, tc.plaintext)
+		}
+	}
+}
+
+func TestEncryptLongVectors(t *testing.T) {
+	for _, tc := range []struct {
+		conversationKey string
+		nonce           string
+		pattern         string
+		repeat          int
+		plaintextHash   string
+		payloadHash     string
+	}{
+		{
+			conversationKey: "8fc262099ce0d0bb9b89bac05bb9e04f9bc0090acc181fef6840ccee470371ed",
+			nonce:           "326bcb2c943cd6bb717588c9e5a7e738edf6ed14ec5f5344caa6ef56f0b9cff7",
+			pattern:         "x",
+			repeat:          65535,
+			plaintextHash:   "09ab7495d3e61a76f0deb12cb0306f0696cbb17ffc12131368c7a939f12f56d3",
+			payloadHash:     "90714492225faba06310bff2f249ebdc2a5e609d65a629f1c87f2d4ffc55330a",
+		},
+		{
+			conversationKey: "7fc540779979e472bb8d12480b443d1e5eb1098eae546ef2390bee499bbf46be",
+			nonce:           "34905e82105c20de9a2f6cd385a0d541e6bcc10601d12481ff3a7575dc622033",
+			pattern:         "🦄",
+			repeat:          16383,
+			plaintextHash:   "a249558d161b77297bc0cb311dde7d77190f6571b25c7e4429cd19044634a61f",
+			payloadHash:     "b3348422471da1f3c59d79acfe2fe103f3cd24488109e5b18734cdb5953afd15",
+		},
+	} {
+		plaintext := strings.Repeat(tc.pattern, tc.repeat)
+		if h := sha256.Sum256([]byte(plaintext)); hex.EncodeToString(h[:]) != tc.plaintextHash {
+			t.Fatalf("unexpected plaintext hash: %x", h)
+		}
+
+		key := decodeHex(t, tc.conversationKey)
+		payload, err := encrypt(key, decodeHex(t, tc.nonce), plaintext)
+		if err != nil {
+			t.Fatalf("encrypt() failed: %s", err)
+		}
+		if h := sha256.Sum256([]byte(payload)); hex.EncodeToString(h[:]) != tc.payloadHash {
+			t.Errorf("encrypt() returned payload with hash %x, expected %s", h, tc.payloadHash)
+		}
+
+		decrypted, err := DecryptWithKey(key, payload)
+		if err != nil {
+			t.Fatalf("DecryptWithKey() failed: %s", err)
+		}
+		if !bytes.Equal([]byte(decrypted), []byte(plaintext)) {
+			t.Errorf("DecryptWithKey() returned unexpected plaintext")
+		}
+	}
+}
+
+func TestDecryptInvalidPayload(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		conversationKey string
+		payload         string
+		message         string
+	}{
+		{
+			name:            "unknown version prefix",
+			conversationKey: "ca2527a037347b91bea0c8a30fc8d9600ffd81ec00038671e3a0f0cb0fc9f642",
+			payload:         "#Atqupco0WyaOW2IGDKcshwxI9xO8HgD/P8Ddt46CbxDbrhdG8VmJdU0MIDf06CUvEvdnr1cp1fiMtlM/GrE92xAc1K5odTpCzUB+mjXgbaqtntBUbTToSUoT0ovrlPwzGjyp",
+			message:         "unknown version",
+		},
+		{
+			name:            "unknown version",
+			conversationKey: "36f04e558af246352dcf73b692fbd3646a2207bd8abd4b1cd26b234db84d9481",
+			payload:         "AK1AjUvoYW3IS7C/BGRUoqEC7ayTfDUgnEPNeWTF/reBZFaha6EAIRueE9D1B1RuoiuFScC0Q94yjIuxZD3JStQtE8JMNacWFs9rlYP+ZydtHhRucp+lxfdvFlaGV/sQlqZz",
+			message:         "unknown version",
+		},
+		{
+			name:            "invalid base64",
+			conversationKey: "ca2527a037347b91bea0c8a30fc8d9600ffd81ec00038671e3a0f0cb0fc9f642",
+			payload:         "Atфupco0WyaOW2IGDKcshwxI9xO8HgD/P8Ddt46CbxDbrhdG8VmJZE0UICD06CUvEvdnr1cp1fiMtlM/GrE92xAc1EwsVCQEgWEu2gsHUVf4JAa3TpgkmFc3TWsax0v6n/Wq",
+			message:         "invalid base64",
+		},
+		{
+			name:            "invalid hmac",
+			conversationKey: "cff7bd6a3e29a450fd27f6c125d5edeb0987c475fd1e8d97591e0d4d8a89763c",
+			payload:         "Agn/l3ULCEAS4V7LhGFM6IGA17jsDUaFCKhrbXDANholyySBfeh+EN8wNB9gaLlg4j6wdBYh+3oK+mnxWu3NKRbSvQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			message:         "invalid hmac",
+		},
+		{
+			name:            "invalid hmac with wrong key",
+			conversationKey: "cfcc9cf682dfb00b11357f65bdc45e29156b69db424d20b3596919074f5bf957",
+			payload:         "AmWxSwuUmqp9UsQX63U7OQ6K1thLI69L7G2b+j4DoIr0oRWQ8avl4OLqWZiTJ10vIgKrNqjoaX+fNhE9RqmR5g0f6BtUg1ijFMz71MO1D4lQLQfW7+UHva8PGYgQ1QpHlKgR",
+			message:         "invalid hmac",
+		},
+		{
+			name:            "invalid padding",
+			conversationKey: "5254827d29177622d40a7b67cad014fe7137700c3c523903ebbe3e1b74d40214",
+			payload:         "Anq2XbuLvCuONcr7V0UxTh8FAyWoZNEdBHXvdbNmDZHB573MI7R7rrTYftpqmvUpahmBC2sngmI14/L0HjOZ7lWGJlzdh6luiOnGPc46cGxf08MRC4CIuxx3i2Lm0KqgJ7vA",
+			message:         "invalid padding",
+		},
+		{
+			name:            "invalid padding length",
+			conversationKey: "0c4cffb7a6f7e706ec94b2e879f1fc54ff8de38d8db87e11787694d5392d5b3f",
+			payload:         "Am+f1yZnwnOs0jymZTcRpwhDRHTdnrFcPtsBzpqVdD6b2NZDaNm/TPkZGr75kbB6tCSoq7YRcbPiNfJXNch3Tf+o9+zZTMxwjgX/nm3yDKR2kHQMBhVleCB9uPuljl40AJ8kXRD0gjw+aYRJFUMK9gCETZAjjmrsCM+nGRZ1FfNsHr6Z",
+			message:         "invalid padding",
+		},
+		{
+			name:            "empty payload",
+			conversationKey: "5cd2d13b9e355aeb2452afbd3786870dbeecb9d355b12cb0a3b6e9da5744cd35",
+			payload:         "",
+			message:         "unknown version",
+		},
+		{
+			name:            "short payload",
+			conversationKey: "873bb0fc665eb950a8e7d5971965539f6ebd645c83c08cd6a85aafbad0f0bc47",
+			payload:         "AqxgToSh3H7iLYRJjoWAM+vSv/Y1mgNlm6OWWjOYUClrFF8=",
+			message:         "invalid payload length",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecryptWithKey(decodeHex(t, tc.conversationKey), tc.payload)
+			if err == nil {
+				t.Fatalf("DecryptWithKey() succeeded")
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Errorf("DecryptWithKey() returned %q, expected %q", err, tc.message)
+			}
+		})
+	}
+}
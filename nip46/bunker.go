@@ -0,0 +1,259 @@
+package nip46
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/keysigner"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+// maxConcurrentRequests is the number of requests a bunker handles at the same time.
+const maxConcurrentRequests = 16
+
+// A BunkerOption configures a Bunker.
+type BunkerOption func(*Bunker)
+
+// WithSecret accepts a connect request from any client with secret.
+// The secret is single-use: it is accepted only from the first client connecting with it.
+func WithSecret(secret string) BunkerOption {
+	return func(b *Bunker) {
+		b.secret = secret
+	}
+}
+
+// WithAllowedClients accepts connect requests from clients of the public keys
+// without a secret.
+func WithAllowedClients(pubKeys ...string) BunkerOption {
+	return func(b *Bunker) {
+		for _, pubKey := range pubKeys {
+			b.allowed[pubKey] = true
+		}
+	}
+}
+
+// A Bunker is a remote signer that signs events
+// on requests from clients through a relay server.
+type Bunker struct {
+	client *nostr.Client
+	key    nostr.PrivateKey
+	signer *keysigner.Signer
+	pubKey string
+	secret string
+	stop   context.CancelFunc
+
+	allowed map[string]bool // client public keys accepted without a secret
+	sem     chan struct{}   // limits concurrent requests
+
+	mu         sync.Mutex
+	secretUsed bool              // whether a client has connected with the secret
+	authorized map[string][]byte // client public key -> conversation key
+}
+
+// NewBunker creates a bunker that handles requests sent to the public key of key
+// through client.
+// The bunker signs events with key.
+//
+// Clients must send a connect request before any other request.
+// The bunker accepts it only with the secret given by WithSecret
+// or from the clients given by WithAllowedClients,
+// and NewBunker returns an error unless either option is given.
+func NewBunker(ctx context.Context, client *nostr.Client, key nostr.PrivateKey, opts ...BunkerOption) (*Bunker, error) {
+	b := &Bunker{
+		client:     client,
+		key:        key,
+		signer:     keysigner.New(key),
+		pubKey:     key.PublicKey().Hex(),
+		allowed:    make(map[string]bool),
+		sem:        make(chan struct{}, maxConcurrentRequests),
+		authorized: make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.secret == "" && len(b.allowed) == 0 {
+		return nil, errors.New("no secret or allowed clients to authorize clients")
+	}
+
+	filter := nostr.Filter{
+		Kinds: []nostr.EventKind{nostr.EventKindNostrConnect},
+		Tags:  []nostr.Tag{{"p", b.pubKey}},
+		Since: time.Now().Unix(),
+	}
+	stop, err := listen(ctx, client, filter, func(ctx context.Context, event *nostr.Event) {
+		select {
+		case b.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		go func() {
+			defer func() { <-b.sem }()
+			b.handleRequest(event)
+		}()
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.stop = stop
+	return b, nil
+}
+
+// URI returns a connection token for clients.
+// The secret in it is accepted only once.
+func (b *Bunker) URI(relays ...string) *BunkerURI {
+	return &BunkerURI{
+		PubKey: b.pubKey,
+		Relays: relays,
+		Secret: b.secret,
+	}
+}
+
+// Close stops handling requests.
+// It does not close the client.
+func (b *Bunker) Close() error {
+	b.stop()
+	return nil
+}
+
+func (b *Bunker) handleRequest(event *nostr.Event) {
+	if err := event.Verify(); err != nil {
+		return
+	}
+	convKey, err := b.conversationKey(event.PubKey)
+	if err != nil {
+		return
+	}
+	plaintext, err := nip44.DecryptWithKey(convKey, event.Content)
+	if err != nil {
+		return
+	}
+	var req request
+	if err := json.Unmarshal([]byte(plaintext), &req); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp := &response{ID: req.ID}
+	result, err := b.handle(ctx, event.PubKey, convKey, &req)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+
+	reply, err := newMessage(&b.key, convKey, event.PubKey, resp)
+	if err != nil {
+		return
+	}
+	_, _ = b.client.Publish(ctx, reply)
+}
+
+func (b *Bunker) handle(ctx context.Context, clientPubKey string, convKey []byte, req *request) (string, error) {
+	if req.Method == MethodConnect {
+		return b.connect(clientPubKey, convKey, req.Params)
+	}
+	if !b.isAuthorized(clientPubKey) {
+		return "", errors.New("unauthorized")
+	}
+
+	switch req.Method {
+	case MethodPing:
+		return "pong", nil
+	case MethodGetPublicKey:
+		return b.pubKey, nil
+	case MethodSignEvent:
+		if len(req.Params) < 1 {
+			return "", errors.New("missing event")
+		}
+		var event nostr.Event
+		if err := json.Unmarshal([]byte(req.Params[0]), &event); err != nil {
+			return "", fmt.Errorf("invalid event: %w", err)
+		}
+		if event.Tags == nil {
+			event.Tags = []nostr.Tag{}
+		}
+		if err := b.signer.SignEvent(ctx, &event); err != nil {
+			return "", err
+		}
+		out, err := json.Marshal(&event)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	case MethodNIP04Encrypt, MethodNIP04Decrypt, MethodNIP44Encrypt, MethodNIP44Decrypt:
+		if len(req.Params) < 2 {
+			return "", errors.New("missing params")
+		}
+		pubKey, text := req.Params[0], req.Params[1]
+		switch req.Method {
+		case MethodNIP04Encrypt:
+			return b.signer.NIP04Encrypt(ctx, pubKey, text)
+		case MethodNIP04Decrypt:
+			return b.signer.NIP04Decrypt(ctx, pubKey, text)
+		case MethodNIP44Encrypt:
+			return b.signer.NIP44Encrypt(ctx, pubKey, text)
+		default:
+			return b.signer.NIP44Decrypt(ctx, pubKey, text)
+		}
+	default:
+		return "", fmt.Errorf("unsupported method: %s", req.Method)
+	}
+}
+
+func (b *Bunker) connect(clientPubKey string, convKey []byte, params []string) (string, error) {
+	if len(params) < 1 || params[0] != b.pubKey {
+		return "", errors.New("invalid remote signer public key")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// authorized clients may connect again without the secret
+	if _, ok := b.authorized[clientPubKey]; !ok && !b.allowed[clientPubKey] {
+		var secret string
+		if len(params) >= 2 {
+			secret = params[1]
+		}
+		if b.secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(b.secret)) != 1 {
+			return "", errors.New("invalid secret")
+		}
+		if b.secretUsed {
+			return "", errors.New("secret already used")
+		}
+		b.secretUsed = true
+	}
+	b.authorized[clientPubKey] = convKey
+	return "ack", nil
+}
+
+func (b *Bunker) isAuthorized(clientPubKey string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.authorized[clientPubKey]
+	return ok
+}
+
+// conversationKey returns the conversation key with the client.
+// Keys are cached only for authorized clients
+// not to keep keys for every public key sending requests.
+func (b *Bunker) conversationKey(clientPubKey string) ([]byte, error) {
+	b.mu.Lock()
+	key, ok := b.authorized[clientPubKey]
+	b.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	pk, err := nostr.ParsePublicKey(clientPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return nip44.DeriveConversationKey(&b.key, pk)
+}
@@ -0,0 +1,167 @@
+// Package nip46 implements remote signing defined in NIP-46 (Nostr Connect).
+//
+// A client sends requests to a remote signer, called bunker,
+// as kind 24133 events with NIP-44 encrypted content,
+// so that the private key of the user never leaves the bunker.
+package nip46
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+// Methods of requests.
+const (
+	MethodConnect      = "connect"
+	MethodGetPublicKey = "get_public_key"
+	MethodSignEvent    = "sign_event"
+	MethodPing         = "ping"
+	MethodNIP04Encrypt = "nip04_encrypt"
+	MethodNIP04Decrypt = "nip04_decrypt"
+	MethodNIP44Encrypt = "nip44_encrypt"
+	MethodNIP44Decrypt = "nip44_decrypt"
+)
+
+// BunkerScheme is the URI scheme of connection tokens provided by bunkers.
+const BunkerScheme = "bunker"
+
+type request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+type response struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// An Error is an error returned by the bunker.
+type Error struct {
+	Method  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Method, e.Message)
+}
+
+// A BunkerURI is a connection token in the form of
+// "bunker://<remote signer public key>?relay=<relay URL>&secret=<secret>".
+type BunkerURI struct {
+	PubKey string
+	Relays []string
+	Secret string // optional
+}
+
+// ParseBunkerURI parses a bunker:// URI.
+func ParseBunkerURI(s string) (*BunkerURI, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != BunkerScheme {
+		return nil, fmt.Errorf("unexpected scheme: %s", u.Scheme)
+	}
+	if _, err := nostr.ParsePublicKey(u.Host); err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	relays := query["relay"]
+	if len(relays) == 0 {
+		return nil, errors.New("missing relay")
+	}
+	return &BunkerURI{
+		PubKey: u.Host,
+		Relays: relays,
+		Secret: query.Get("secret"),
+	}, nil
+}
+
+func (u *BunkerURI) String() string {
+	query := url.Values{}
+	for _, relay := range u.Relays {
+		query.Add("relay", relay)
+	}
+	if u.Secret != "" {
+		query.Set("secret", u.Secret)
+	}
+	return (&url.URL{
+		Scheme:   BunkerScheme,
+		Host:     u.PubKey,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// newMessage creates an event addressed to pubKey with encrypted payload.
+func newMessage(key *nostr.PrivateKey, conversationKey []byte, pubKey string, payload any) (*nostr.Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	content, err := nip44.EncryptWithKey(conversationKey, string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	event := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindNostrConnect,
+		Tags:      []nostr.Tag{{"p", pubKey}},
+		Content:   content,
+	}
+	if err := event.SignWithKey(key); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// listen subscribes to events matching filter and calls f for each event in background
+// until the returned function is called.
+// It returns after the relay server has sent stored events
+// so that no event published after that is missed.
+func listen(ctx context.Context, client *nostr.Client, filter nostr.Filter, f func(context.Context, *nostr.Event)) (context.CancelFunc, error) {
+	sub, err := client.Subscribe(ctx, []nostr.Filter{filter})
+	if err != nil {
+		return nil, err
+	}
+
+	subCtx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- sub.Receive(subCtx, f)
+	}()
+
+	select {
+	case <-sub.EOSE():
+		return cancel, nil
+	case err := <-errChan:
+		cancel()
+		if err == nil {
+			err = errors.New("subscription closed")
+		}
+		return nil, err
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,263 @@
+package nip46
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip44"
+	"github.com/shota3506/go-nostr/relay"
+	"github.com/shota3506/go-nostr/store"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(relay.New(store.NewMemory()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, url string) *nostr.Client {
+	t.Helper()
+	client, err := nostr.NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newTestKey(t *testing.T) nostr.PrivateKey {
+	t.Helper()
+	key, err := nostr.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestBunker(t *testing.T, url string, key nostr.PrivateKey, opts ...BunkerOption) *Bunker {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	bunker, err := NewBunker(ctx, newTestClient(t, url), key, opts...)
+	if err != nil {
+		t.Fatalf("NewBunker() failed: %s", err)
+	}
+	t.Cleanup(func() { bunker.Close() })
+	return bunker
+}
+
+func TestBunkerURI(t *testing.T) {
+	pubKey := "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	s := "bunker://" + pubKey + "?relay=wss%3A%2F%2Frelay1.example.com&relay=wss%3A%2F%2Frelay2.example.com&secret=abc"
+
+	uri, err := ParseBunkerURI(s)
+	if err != nil {
+		t.Fatalf("ParseBunkerURI() failed: %s", err)
+	}
+	expected := &BunkerURI{
+		PubKey: pubKey,
+		Relays: []string{"wss://relay1.example.com", "wss://relay2.example.com"},
+		Secret: "abc",
+	}
+	if !reflect.DeepEqual(uri, expected) {
+		t.Errorf("ParseBunkerURI() returned %+v, expected %+v", uri, expected)
+	}
+	if uri.String() != s {
+		t.Errorf("uri.String() returned %s, expected %s", uri.String(), s)
+	}
+
+	for _, tc := range []struct {
+		name string
+		s    string
+	}{
+		{name: "wrong scheme", s: "nostrconnect://" + pubKey + "?relay=wss%3A%2F%2Frelay.example.com"},
+		{name: "invalid public key", s: "bunker://" + pubKey[:62] + "?relay=wss%3A%2F%2Frelay.example.com"},
+		{name: "missing relay", s: "bunker://" + pubKey},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseBunkerURI(tc.s); err == nil {
+				t.Errorf("ParseBunkerURI() succeeded")
+			}
+		})
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	server := newTestServer(t)
+	userKey := newTestKey(t)
+	bunker := newTestBunker(t, server.URL, userKey, WithSecret("secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	signer, err := ConnectBunker(ctx, bunker.URI(server.URL).String(), newTestKey(t))
+	if err != nil {
+		t.Fatalf("ConnectBunker() failed: %s", err)
+	}
+	defer signer.Close()
+
+	t.Run("ping", func(t *testing.T) {
+		if err := signer.Ping(ctx); err != nil {
+			t.Errorf("signer.Ping() failed: %s", err)
+		}
+	})
+
+	t.Run("public key", func(t *testing.T) {
+		pubKey, err := signer.PublicKey(ctx)
+		if err != nil {
+			t.Fatalf("signer.PublicKey() failed: %s", err)
+		}
+		if expected := userKey.PublicKey().Hex(); pubKey != expected {
+			t.Errorf("signer.PublicKey() returned %s, expected %s", pubKey, expected)
+		}
+	})
+
+	t.Run("sign event", func(t *testing.T) {
+		event := &nostr.Event{
+			CreatedAt: time.Now().Unix(),
+			Kind:      nostr.EventKindTextNote,
+			Tags:      []nostr.Tag{{"t", "nostr"}},
+			Content:   "signed remotely",
+		}
+		if err := signer.SignEvent(ctx, event); err != nil {
+			t.Fatalf("signer.SignEvent() failed: %s", err)
+		}
+		if event.PubKey != userKey.PublicKey().Hex() {
+			t.Errorf("event.PubKey is %s", event.PubKey)
+		}
+		if err := event.Verify(); err != nil {
+			t.Errorf("event.Verify() failed: %s", err)
+		}
+	})
+
+	t.Run("nip44", func(t *testing.T) {
+		peerKey := newTestKey(t)
+		ciphertext, err := signer.NIP44Encrypt(ctx, peerKey.PublicKey().Hex(), "hello")
+		if err != nil {
+			t.Fatalf("signer.NIP44Encrypt() failed: %s", err)
+		}
+		plaintext, err := nip44.Decrypt(peerKey.Hex(), userKey.PublicKey().Hex(), ciphertext)
+		if err != nil {
+			t.Fatalf("nip44.Decrypt() failed: %s", err)
+		}
+		if plaintext != "hello" {
+			t.Errorf("nip44.Decrypt() returned %s", plaintext)
+		}
+
+		plaintext, err = signer.NIP44Decrypt(ctx, peerKey.PublicKey().Hex(), ciphertext)
+		if err != nil {
+			t.Fatalf("signer.NIP44Decrypt() failed: %s", err)
+		}
+		if plaintext != "hello" {
+			t.Errorf("signer.NIP44Decrypt() returned %s", plaintext)
+		}
+	})
+}
+
+func TestRemoteSignerUnauthorized(t *testing.T) {
+	server := newTestServer(t)
+	bunker := newTestBunker(t, server.URL, newTestKey(t), WithSecret("secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uri := bunker.URI(server.URL)
+	uri.Secret = "wrong"
+	_, err := ConnectBunker(ctx, uri.String(), newTestKey(t))
+	var bunkerErr *Error
+	if !errors.As(err, &bunkerErr) {
+		t.Fatalf("ConnectBunker() returned %v, expected bunker error", err)
+	}
+
+	// a client that never connected
+	signer, err := NewRemoteSigner(ctx, newTestClient(t, server.URL), newTestKey(t), uri.PubKey)
+	if err != nil {
+		t.Fatalf("NewRemoteSigner() failed: %s", err)
+	}
+	defer signer.Close()
+	if _, err := signer.PublicKey(ctx); !errors.As(err, &bunkerErr) || bunkerErr.Message != "unauthorized" {
+		t.Errorf("signer.PublicKey() returned %v, expected unauthorized error", err)
+	}
+}
+
+func TestRemoteSignerSecretReused(t *testing.T) {
+	server := newTestServer(t)
+	bunker := newTestBunker(t, server.URL, newTestKey(t), WithSecret("secret"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uri := bunker.URI(server.URL).String()
+	clientKey := newTestKey(t)
+	signer, err := ConnectBunker(ctx, uri, clientKey)
+	if err != nil {
+		t.Fatalf("ConnectBunker() failed: %s", err)
+	}
+	defer signer.Close()
+
+	// the connected client may connect again
+	if err := signer.Connect(ctx, ""); err != nil {
+		t.Errorf("signer.Connect() failed: %s", err)
+	}
+
+	// another client with the used secret
+	var bunkerErr *Error
+	if _, err := ConnectBunker(ctx, uri, newTestKey(t)); !errors.As(err, &bunkerErr) {
+		t.Fatalf("ConnectBunker() returned %v, expected bunker error", err)
+	}
+}
+
+func TestRemoteSignerAllowedClients(t *testing.T) {
+	server := newTestServer(t)
+	userKey := newTestKey(t)
+	allowedKey, otherKey := newTestKey(t), newTestKey(t)
+	bunker := newTestBunker(t, server.URL, userKey, WithAllowedClients(allowedKey.PublicKey().Hex()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uri := bunker.URI(server.URL)
+	signer, err := ConnectBunker(ctx, uri.String(), allowedKey)
+	if err != nil {
+		t.Fatalf("ConnectBunker() failed: %s", err)
+	}
+	defer signer.Close()
+	pubKey, err := signer.PublicKey(ctx)
+	if err != nil {
+		t.Fatalf("signer.PublicKey() failed: %s", err)
+	}
+	if expected := userKey.PublicKey().Hex(); pubKey != expected {
+		t.Errorf("signer.PublicKey() returned %s, expected %s", pubKey, expected)
+	}
+
+	// a client that is not allowed
+	var bunkerErr *Error
+	if _, err := ConnectBunker(ctx, uri.String(), otherKey); !errors.As(err, &bunkerErr) {
+		t.Fatalf("ConnectBunker() returned %v, expected bunker error", err)
+	}
+	other, err := NewRemoteSigner(ctx, newTestClient(t, server.URL), otherKey, uri.PubKey)
+	if err != nil {
+		t.Fatalf("NewRemoteSigner() failed: %s", err)
+	}
+	defer other.Close()
+	if _, err := other.PublicKey(ctx); !errors.As(err, &bunkerErr) || bunkerErr.Message != "unauthorized" {
+		t.Errorf("other.PublicKey() returned %v, expected unauthorized error", err)
+	}
+}
+
+func TestNewBunkerWithoutAuthorization(t *testing.T) {
+	server := newTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := NewBunker(ctx, newTestClient(t, server.URL), newTestKey(t)); err == nil {
+		t.Errorf("NewBunker() succeeded without secret or allowed clients")
+	}
+}
@@ -0,0 +1,337 @@
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+var (
+	_ nostr.Signer         = (*RemoteSigner)(nil)
+	_ nostr.NIP04Encrypter = (*RemoteSigner)(nil)
+	_ nostr.NIP44Encrypter = (*RemoteSigner)(nil)
+)
+
+// A RemoteSignerOption configures a RemoteSigner.
+type RemoteSignerOption func(*RemoteSigner)
+
+// WithAuthURLHandler registers f to be called when the bunker requires
+// the user to open url to authorize a request.
+// The request keeps waiting for the response after f is called.
+func WithAuthURLHandler(f func(url string)) RemoteSignerOption {
+	return func(s *RemoteSigner) {
+		s.authURLHandler = f
+	}
+}
+
+// A RemoteSigner is a nostr.Signer that asks a bunker to sign events.
+type RemoteSigner struct {
+	client       *nostr.Client
+	ownsClient   bool
+	key          nostr.PrivateKey
+	remotePubKey string
+
+	conversationKey []byte
+	stop            context.CancelFunc
+	pending         sync.Map // map[string]chan *response
+
+	mu     sync.Mutex
+	pubKey string
+
+	authURLHandler func(url string)
+}
+
+// NewRemoteSigner creates a remote signer that sends requests
+// to the bunker of remotePubKey through client.
+// key is a key of the client used only to communicate with the bunker,
+// which is different from the key of the user.
+//
+// It does not send a connect request. Call Connect if the bunker requires it.
+func NewRemoteSigner(ctx context.Context, client *nostr.Client, key nostr.PrivateKey, remotePubKey string, opts ...RemoteSignerOption) (*RemoteSigner, error) {
+	pk, err := nostr.ParsePublicKey(remotePubKey)
+	if err != nil {
+		return nil, err
+	}
+	conversationKey, err := nip44.DeriveConversationKey(&key, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &RemoteSigner{
+		client:          client,
+		key:             key,
+		remotePubKey:    remotePubKey,
+		conversationKey: conversationKey,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	filter := nostr.Filter{
+		Kinds:   []nostr.EventKind{nostr.EventKindNostrConnect},
+		Authors: []string{remotePubKey},
+		Tags:    []nostr.Tag{{"p", key.PublicKey().Hex()}},
+		Since:   time.Now().Unix(),
+	}
+	stop, err := listen(ctx, client, filter, s.handleResponse)
+	if err != nil {
+		return nil, err
+	}
+	s.stop = stop
+	return s, nil
+}
+
+// ConnectBunker connects to the bunker described by a bunker:// URI
+// and sends a connect request with the secret in it.
+// It uses the first relay server in the URI that it can connect to.
+// The returned signer owns the connection and closes it on Close.
+func ConnectBunker(ctx context.Context, uri string, key nostr.PrivateKey, opts ...RemoteSignerOption) (*RemoteSigner, error) {
+	bunker, err := ParseBunkerURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		client *nostr.Client
+		errs   []error
+	)
+	for _, relay := range bunker.Relays {
+		client, err = nostr.NewClient(relay)
+		if err == nil {
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", relay, err))
+	}
+	if client == nil {
+		return nil, errors.Join(errs...)
+	}
+
+	s, err := NewRemoteSigner(ctx, client, key, bunker.PubKey, opts...)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	s.ownsClient = true
+
+	if err := s.Connect(ctx, bunker.Secret); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Connect sends a connect request to the bunker.
+func (s *RemoteSigner) Connect(ctx context.Context, secret string) error {
+	params := []string{s.remotePubKey}
+	if secret != "" {
+		params = append(params, secret)
+	}
+	result, err := s.call(ctx, MethodConnect, params...)
+	if err != nil {
+		return err
+	}
+	// the bunker may return the secret instead of "ack"
+	if result != "ack" && (secret == "" || result != secret) {
+		return fmt.Errorf("unexpected connect result: %s", result)
+	}
+	return nil
+}
+
+// Ping sends a ping request to the bunker.
+func (s *RemoteSigner) Ping(ctx context.Context) error {
+	result, err := s.call(ctx, MethodPing)
+	if err != nil {
+		return err
+	}
+	if result != "pong" {
+		return fmt.Errorf("unexpected ping result: %s", result)
+	}
+	return nil
+}
+
+// PublicKey returns the public key of the user.
+// The result is cached after the first request.
+func (s *RemoteSigner) PublicKey(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	pubKey := s.pubKey
+	s.mu.Unlock()
+	if pubKey != "" {
+		return pubKey, nil
+	}
+
+	result, err := s.call(ctx, MethodGetPublicKey)
+	if err != nil {
+		return "", err
+	}
+	if _, err := nostr.ParsePublicKey(result); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.pubKey = result
+	s.mu.Unlock()
+	return result, nil
+}
+
+// SignEvent asks the bunker to sign the event.
+// It verifies that the signed event is the same as the requested one.
+func (s *RemoteSigner) SignEvent(ctx context.Context, event *nostr.Event) error {
+	pubKey, err := s.PublicKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	tags := event.Tags
+	if tags == nil {
+		tags = []nostr.Tag{}
+	}
+	b, err := json.Marshal(struct {
+		Kind      nostr.EventKind `json:"kind"`
+		Content   string          `json:"content"`
+		Tags      []nostr.Tag     `json:"tags"`
+		CreatedAt int64           `json:"created_at"`
+	}{
+		Kind:      event.Kind,
+		Content:   event.Content,
+		Tags:      tags,
+		CreatedAt: event.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := s.call(ctx, MethodSignEvent, string(b))
+	if err != nil {
+		return err
+	}
+	var signed nostr.Event
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("invalid signed event: %w", err)
+	}
+
+	if signed.PubKey != pubKey ||
+		signed.Kind != event.Kind ||
+		signed.Content != event.Content ||
+		signed.CreatedAt != event.CreatedAt ||
+		!reflect.DeepEqual(signed.Tags, tags) {
+		return errors.New("signed event does not match the request")
+	}
+	if err := signed.Verify(); err != nil {
+		return err
+	}
+
+	event.ID = signed.ID
+	event.PubKey = signed.PubKey
+	event.Sig = signed.Sig
+	return nil
+}
+
+func (s *RemoteSigner) NIP04Encrypt(ctx context.Context, pubKey, plaintext string) (string, error) {
+	return s.call(ctx, MethodNIP04Encrypt, pubKey, plaintext)
+}
+
+func (s *RemoteSigner) NIP04Decrypt(ctx context.Context, pubKey, ciphertext string) (string, error) {
+	return s.call(ctx, MethodNIP04Decrypt, pubKey, ciphertext)
+}
+
+func (s *RemoteSigner) NIP44Encrypt(ctx context.Context, pubKey, plaintext string) (string, error) {
+	return s.call(ctx, MethodNIP44Encrypt, pubKey, plaintext)
+}
+
+func (s *RemoteSigner) NIP44Decrypt(ctx context.Context, pubKey, ciphertext string) (string, error) {
+	return s.call(ctx, MethodNIP44Decrypt, pubKey, ciphertext)
+}
+
+// Close stops receiving responses from the bunker.
+// It also closes the connection if the signer is created by ConnectBunker.
+func (s *RemoteSigner) Close() error {
+	s.stop()
+	if s.ownsClient {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// call sends a request to the bunker and waits for the response.
+func (s *RemoteSigner) call(ctx context.Context, method string, params ...string) (string, error) {
+	id, err := newRequestID()
+	if err != nil {
+		return "", err
+	}
+	if params == nil {
+		params = []string{}
+	}
+	event, err := newMessage(&s.key, s.conversationKey, s.remotePubKey, &request{
+		ID:     id,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	respChan := make(chan *response, 1)
+	s.pending.Store(id, respChan)
+	defer s.pending.Delete(id)
+
+	result, err := s.client.Publish(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	if !result.OK {
+		return "", fmt.Errorf("request rejected by relay server: %s", result.Message)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("missing response: %w", ctx.Err())
+		case resp := <-respChan:
+			if resp.Result == "auth_url" {
+				if s.authURLHandler != nil {
+					s.authURLHandler(resp.Error)
+				}
+				continue
+			}
+			if resp.Error != "" {
+				return "", &Error{Method: method, Message: resp.Error}
+			}
+			return resp.Result, nil
+		}
+	}
+}
+
+func (s *RemoteSigner) handleResponse(_ context.Context, event *nostr.Event) {
+	if err := event.Verify(); err != nil {
+		return
+	}
+	plaintext, err := nip44.DecryptWithKey(s.conversationKey, event.Content)
+	if err != nil {
+		return
+	}
+	var resp response
+	if err := json.Unmarshal([]byte(plaintext), &resp); err != nil {
+		return
+	}
+
+	value, ok := s.pending.Load(resp.ID)
+	if !ok {
+		return
+	}
+	respChan, ok := value.(chan *response)
+	if !ok {
+		return
+	}
+	select {
+	case respChan <- &resp:
+	default:
+		// drop response
+	}
+}
@@ -0,0 +1,215 @@
+// Package nip59 implements seals and gift wraps defined in NIP-59.
+//
+// A rumor is an unsigned event. It is encrypted into a seal signed by the author,
+// and the seal is encrypted into a gift wrap signed by a one-time key,
+// so that relays can see neither the author nor the content.
+package nip59
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+// maxTimestampSkew is the maximum duration that created_at of seals and gift wraps
+// is moved into the past to hide the time of the rumor.
+const maxTimestampSkew = 2 * 24 * time.Hour
+
+// ErrAuthorMismatch is returned when the author of a rumor is not the signer of its seal.
+var ErrAuthorMismatch = errors.New("rumor author does not match seal signer")
+
+// unsignedEvent is the JSON representation of a rumor, which has no signature.
+type unsignedEvent struct {
+	ID        string          `json:"id"`
+	PubKey    string          `json:"pubkey"`
+	CreatedAt int64           `json:"created_at"`
+	Kind      nostr.EventKind `json:"kind"`
+	Tags      []nostr.Tag     `json:"tags"`
+	Content   string          `json:"content"`
+}
+
+// Seal encrypts rumor for the owner of recipientPubKey
+// and returns a seal event signed with privKey.
+// The PubKey and ID fields of rumor are filled in and its signature is removed.
+func Seal(privKey string, rumor *nostr.Event, recipientPubKey string) (*nostr.Event, error) {
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	if rumor.PubKey != "" && rumor.PubKey != pubKey {
+		return nil, ErrAuthorMismatch
+	}
+	rumor.PubKey = pubKey
+	rumor.Sig = ""
+	if rumor.ID, err = rumor.ComputeID(); err != nil {
+		return nil, err
+	}
+
+	b, err := marshalRumor(rumor)
+	if err != nil {
+		return nil, err
+	}
+	content, err := nip44.Encrypt(privKey, recipientPubKey, string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := randomTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	seal := &nostr.Event{
+		CreatedAt: createdAt,
+		Kind:      nostr.EventKindSeal,
+		Tags:      []nostr.Tag{},
+		Content:   content,
+	}
+	if err := seal.Sign(privKey); err != nil {
+		return nil, err
+	}
+	return seal, nil
+}
+
+// Wrap encrypts seal for the owner of recipientPubKey
+// and returns a gift wrap event signed with a newly generated key.
+func Wrap(seal *nostr.Event, recipientPubKey string) (*nostr.Event, error) {
+	if seal.Kind != nostr.EventKindSeal {
+		return nil, fmt.Errorf("unexpected event kind: %d", seal.Kind)
+	}
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(seal)
+	if err != nil {
+		return nil, err
+	}
+	content, err := nip44.Encrypt(privKey, recipientPubKey, string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := randomTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	wrap := &nostr.Event{
+		CreatedAt: createdAt,
+		Kind:      nostr.EventKindGiftWrap,
+		Tags:      []nostr.Tag{{"p", recipientPubKey}},
+		Content:   content,
+	}
+	if err := wrap.Sign(privKey); err != nil {
+		return nil, err
+	}
+	return wrap, nil
+}
+
+// GiftWrap seals rumor with privKey and wraps the seal for the owner of recipientPubKey.
+func GiftWrap(privKey string, rumor *nostr.Event, recipientPubKey string) (*nostr.Event, error) {
+	seal, err := Seal(privKey, rumor, recipientPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(seal, recipientPubKey)
+}
+
+// Unwrap decrypts a gift wrap addressed to the owner of privKey and returns the rumor.
+// It verifies the signatures of the gift wrap and the seal,
+// and that the rumor is authored by the signer of the seal.
+func Unwrap(privKey string, wrap *nostr.Event) (*nostr.Event, error) {
+	seal, err := UnwrapSeal(privKey, wrap)
+	if err != nil {
+		return nil, err
+	}
+	return Unseal(privKey, seal)
+}
+
+// UnwrapSeal decrypts a gift wrap addressed to the owner of privKey and returns the seal.
+// The seal is not verified; use Unseal to verify and decrypt it.
+func UnwrapSeal(privKey string, wrap *nostr.Event) (*nostr.Event, error) {
+	if wrap.Kind != nostr.EventKindGiftWrap {
+		return nil, fmt.Errorf("unexpected event kind: %d", wrap.Kind)
+	}
+	if err := wrap.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid gift wrap: %w", err)
+	}
+
+	plaintext, err := nip44.Decrypt(privKey, wrap.PubKey, wrap.Content)
+	if err != nil {
+		return nil, err
+	}
+	var seal nostr.Event
+	if err := json.Unmarshal([]byte(plaintext), &seal); err != nil {
+		return nil, fmt.Errorf("invalid seal: %w", err)
+	}
+	return &seal, nil
+}
+
+// Unseal decrypts a seal addressed to the owner of privKey and returns the rumor.
+// It verifies the signature of the seal and that the rumor is authored by its signer.
+func Unseal(privKey string, seal *nostr.Event) (*nostr.Event, error) {
+	if seal.Kind != nostr.EventKindSeal {
+		return nil, fmt.Errorf("unexpected event kind: %d", seal.Kind)
+	}
+	if err := seal.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid seal: %w", err)
+	}
+
+	plaintext, err := nip44.Decrypt(privKey, seal.PubKey, seal.Content)
+	if err != nil {
+		return nil, err
+	}
+	var r unsignedEvent
+	if err := json.Unmarshal([]byte(plaintext), &r); err != nil {
+		return nil, fmt.Errorf("invalid rumor: %w", err)
+	}
+
+	event := &nostr.Event{
+		ID:        r.ID,
+		PubKey:    r.PubKey,
+		CreatedAt: r.CreatedAt,
+		Kind:      r.Kind,
+		Tags:      r.Tags,
+		Content:   r.Content,
+	}
+	if event.PubKey != seal.PubKey {
+		return nil, ErrAuthorMismatch
+	}
+	if !event.CheckID() {
+		return nil, nostr.ErrInvalidID
+	}
+	return event, nil
+}
+
+func marshalRumor(event *nostr.Event) ([]byte, error) {
+	tags := event.Tags
+	if tags == nil {
+		tags = []nostr.Tag{}
+	}
+	return json.Marshal(unsignedEvent{
+		ID:        event.ID,
+		PubKey:    event.PubKey,
+		CreatedAt: event.CreatedAt,
+		Kind:      event.Kind,
+		Tags:      tags,
+		Content:   event.Content,
+	})
+}
+
+// randomTimestamp returns a timestamp randomly chosen within maxTimestampSkew in the past.
+func randomTimestamp() (int64, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(maxTimestampSkew/time.Second)))
+	if err != nil {
+		return 0, err
+	}
+	return time.Now().Unix() - n.Int64(), nil
+}
@@ -0,0 +1,155 @@
+package nip59
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/nip44"
+)
+
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := nostr.PublicKeyFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privKey, pubKey
+}
+
+func newRumor() *nostr.Event {
+	return &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindPrivateDirectMessage,
+		Content:   "hello",
+	}
+}
+
+func TestGiftWrap(t *testing.T) {
+	alicePriv, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+
+	rumor := newRumor()
+	wrap, err := GiftWrap(alicePriv, rumor, bobPub)
+	if err != nil {
+		t.Fatalf("GiftWrap() failed: %s", err)
+	}
+
+	if wrap.Kind != nostr.EventKindGiftWrap {
+		t.Errorf("unexpected kind: %d", wrap.Kind)
+	}
+	if wrap.PubKey == alicePub {
+		t.Errorf("gift wrap is signed by the author")
+	}
+	if len(wrap.Tags) != 1 || wrap.Tags[0][0] != "p" || wrap.Tags[0][1] != bobPub {
+		t.Errorf("unexpected tags: %v", wrap.Tags)
+	}
+	now := time.Now().Unix()
+	if wrap.CreatedAt > now || wrap.CreatedAt < now-int64(maxTimestampSkew/time.Second) {
+		t.Errorf("unexpected created_at: %d", wrap.CreatedAt)
+	}
+	if err := wrap.Verify(); err != nil {
+		t.Fatalf("Verify() failed: %s", err)
+	}
+
+	unwrapped, err := Unwrap(bobPriv, wrap)
+	if err != nil {
+		t.Fatalf("Unwrap() failed: %s", err)
+	}
+	if unwrapped.PubKey != alicePub {
+		t.Errorf("unexpected author: %s", unwrapped.PubKey)
+	}
+	if unwrapped.ID != rumor.ID {
+		t.Errorf("unexpected id: %s, expected %s", unwrapped.ID, rumor.ID)
+	}
+	if unwrapped.Content != rumor.Content || unwrapped.CreatedAt != rumor.CreatedAt {
+		t.Errorf("unexpected rumor: %+v", unwrapped)
+	}
+	if unwrapped.Sig != "" {
+		t.Errorf("rumor has signature: %s", unwrapped.Sig)
+	}
+}
+
+func TestUnwrapWrongRecipient(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	_, bobPub := newKeyPair(t)
+	malloryPriv, _ := newKeyPair(t)
+
+	wrap, err := GiftWrap(alicePriv, newRumor(), bobPub)
+	if err != nil {
+		t.Fatalf("GiftWrap() failed: %s", err)
+	}
+	if _, err := Unwrap(malloryPriv, wrap); err == nil {
+		t.Errorf("Unwrap() succeeded with wrong key")
+	}
+}
+
+func TestUnwrapTampered(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+
+	wrap, err := GiftWrap(alicePriv, newRumor(), bobPub)
+	if err != nil {
+		t.Fatalf("GiftWrap() failed: %s", err)
+	}
+	wrap.CreatedAt++
+	if _, err := Unwrap(bobPriv, wrap); !errors.Is(err, nostr.ErrInvalidID) {
+		t.Errorf("Unwrap() returned %v, expected %v", err, nostr.ErrInvalidID)
+	}
+}
+
+func TestUnwrapAuthorMismatch(t *testing.T) {
+	_, alicePub := newKeyPair(t)
+	bobPriv, bobPub := newKeyPair(t)
+	malloryPriv, _ := newKeyPair(t)
+
+	// mallory seals a rumor claiming to be authored by alice
+	rumor := newRumor()
+	rumor.PubKey = alicePub
+	id, err := rumor.ComputeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rumor.ID = id
+	b, err := marshalRumor(rumor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := nip44.Encrypt(malloryPriv, bobPub, string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seal := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostr.EventKindSeal,
+		Tags:      []nostr.Tag{},
+		Content:   content,
+	}
+	if err := seal.Sign(malloryPriv); err != nil {
+		t.Fatal(err)
+	}
+
+	wrap, err := Wrap(seal, bobPub)
+	if err != nil {
+		t.Fatalf("Wrap() failed: %s", err)
+	}
+	if _, err := Unwrap(bobPriv, wrap); !errors.Is(err, ErrAuthorMismatch) {
+		t.Errorf("Unwrap() returned %v, expected %v", err, ErrAuthorMismatch)
+	}
+}
+
+func TestSealAuthorMismatch(t *testing.T) {
+	alicePriv, _ := newKeyPair(t)
+	_, bobPub := newKeyPair(t)
+
+	rumor := newRumor()
+	rumor.PubKey = bobPub
+	if _, err := Seal(alicePriv, rumor, bobPub); !errors.Is(err, ErrAuthorMismatch) {
+		t.Errorf("Seal() returned %v, expected %v", err, ErrAuthorMismatch)
+	}
+}
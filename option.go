@@ -0,0 +1,67 @@
+package nostr
+
+// A ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithEventVerification makes the client verify the ID and signature of
+// every event received from the relay server before delivering it to subscriptions.
+// Events that fail verification are dropped and reported to onError if it is not nil.
+func WithEventVerification(onError func(subID string, event *Event, err error)) ClientOption {
+	return func(c *Client) {
+		c.verifyEvents = true
+		c.invalidEventHandler = onError
+	}
+}
+
+// WithReconnect makes the client reconnect to the relay server
+// with the given backoff policy when the connection is lost.
+// Requests of active subscriptions are re-sent after reconnection,
+// with their since advanced to the last received event.
+func WithReconnect(backoff Backoff) ClientOption {
+	return func(c *Client) {
+		c.backoff = &backoff
+	}
+}
+
+// WithConnectionStateHandler registers f to be called on every transition of the connection state.
+func WithConnectionStateHandler(f func(ConnectionState)) ClientOption {
+	return func(c *Client) {
+		c.stateHandler = f
+	}
+}
+
+// WithAuthSigner makes the client respond to authentication challenges
+// from the relay server with events signed by signer, as defined in NIP-42.
+// Requests of subscriptions closed by the relay server with "auth-required"
+// before the client is authenticated are re-sent once it is authenticated.
+func WithAuthSigner(signer Signer) ClientOption {
+	return func(c *Client) {
+		c.authSigner = signer
+	}
+}
+
+// WithRelayLimitation makes the client enforce the limitation of the relay server locally.
+// Requests exceeding the limitation are adjusted or rejected with a *LimitError before being sent.
+func WithRelayLimitation(limitation *RelayLimitation) ClientOption {
+	return func(c *Client) {
+		c.limitation = limitation
+	}
+}
+
+// WithRelayInfoLimitation is like WithRelayLimitation,
+// but the limitation is fetched from the relay information document on connection.
+// No limitation is enforced if the relay server does not serve it.
+func WithRelayInfoLimitation() ClientOption {
+	return func(c *Client) {
+		c.fetchInfo = true
+	}
+}
+
+// WithRejectionErrors makes Publish return a *RejectedError when the relay server rejects the event,
+// so that callers can handle failures by the reason without inspecting the command result.
+// Events the relay server already has are not considered rejected.
+func WithRejectionErrors() ClientOption {
+	return func(c *Client) {
+		c.rejectionErrors = true
+	}
+}
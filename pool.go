@@ -0,0 +1,181 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// A Pool is a set of clients connected to multiple relay servers.
+type Pool struct {
+	opts []ClientOption
+
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewPool creates a new empty pool.
+// The options are applied to every client created by the pool.
+func NewPool(opts ...ClientOption) *Pool {
+	return &Pool{
+		opts:    opts,
+		clients: map[string]*Client{},
+	}
+}
+
+// Add connects to the relay server at url and adds it to the pool.
+// It does nothing if the relay server is already in the pool.
+func (p *Pool) Add(url string) error {
+	p.mu.RLock()
+	_, ok := p.clients[url]
+	p.mu.RUnlock()
+	if ok {
+		return nil
+	}
+
+	client, err := NewClient(url, p.opts...)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.clients[url]; ok {
+		return client.Close()
+	}
+	p.clients[url] = client
+	return nil
+}
+
+// Remove closes the connection to the relay server at url and removes it from the pool.
+func (p *Pool) Remove(url string) error {
+	p.mu.Lock()
+	client, ok := p.clients[url]
+	delete(p.clients, url)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return client.Close()
+}
+
+// Relays returns the URLs of the relay servers in the pool.
+func (p *Pool) Relays() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	urls := make([]string, 0, len(p.clients))
+	for url := range p.clients {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Client returns the client connected to the relay server at url.
+func (p *Pool) Client(url string) (*Client, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	client, ok := p.clients[url]
+	return client, ok
+}
+
+// Publish submits an event to all relay servers in the pool
+// and waits for the command results.
+// The results are keyed by relay server URL.
+// If some of the relay servers fail, Publish returns the results of the others
+// together with an error describing the failures.
+func (p *Pool) Publish(ctx context.Context, event *Event) (map[string]*CommandResult, error) {
+	clients := p.snapshot()
+	if len(clients) == 0 {
+		return nil, errors.New("no relay servers in pool")
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = map[string]*CommandResult{}
+		errs    []error
+	)
+	for url, client := range clients {
+		wg.Add(1)
+		go func(url string, client *Client) {
+			defer wg.Done()
+			result, err := client.Publish(ctx, event)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if result != nil {
+				// results of rejected events are kept with WithRejectionErrors
+				results[url] = result
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			}
+		}(url, client)
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// SignAndPublish signs the event with signer and submits it to all relay servers in the pool.
+func (p *Pool) SignAndPublish(ctx context.Context, signer Signer, event *Event) (map[string]*CommandResult, error) {
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to sign event: %w", err)
+	}
+	return p.Publish(ctx, event)
+}
+
+// Subscribe creates a subscription to all relay servers in the pool with the given filters.
+// Events are deduplicated by ID across relay servers.
+// The EOSE channel of the subscription receives a value
+// once all relay servers have sent their stored events.
+// The options are applied to the subscription to each relay server.
+// The subscription is closed with the errors only after all relay servers have closed it.
+func (p *Pool) Subscribe(ctx context.Context, filters []Filter, opts ...SubscriptionOption) (*Subscription, error) {
+	clients := p.snapshot()
+	if len(clients) == 0 {
+		return nil, errors.New("no relay servers in pool")
+	}
+
+	subs := make([]*Subscription, 0, len(clients))
+	for _, client := range clients {
+		sub, err := client.Subscribe(ctx, filters, opts...)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return mergeSubscriptions(subs, false), nil
+}
+
+// Close closes all connections in the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = map[string]*Client{}
+	p.mu.Unlock()
+
+	var errs []error
+	for url, client := range clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) snapshot() map[string]*Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	clients := make(map[string]*Client, len(p.clients))
+	for url, client := range p.clients {
+		clients[url] = client
+	}
+	return clients
+}
@@ -0,0 +1,294 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// newPoolTestServer starts a relay server emulator
+// which accepts every event and responds to requests with the given events.
+func newPoolTestServer(events []*Event) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close(websocket.StatusInternalError, "")
+
+		for {
+			var message []json.RawMessage
+			if err := wsjson.Read(ctx, conn, &message); err != nil {
+				return
+			}
+			var typ string
+			if err = json.Unmarshal(message[0], &typ); err != nil {
+				return
+			}
+
+			switch typ {
+			case "EVENT":
+				var event Event
+				if err = json.Unmarshal(message[1], &event); err != nil {
+					return
+				}
+				if err := wsjson.Write(ctx, conn, []any{"OK", event.ID, true, r.Host}); err != nil {
+					return
+				}
+			case "REQ":
+				var subscriptionID string
+				if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+					return
+				}
+				for _, event := range events {
+					if err := wsjson.Write(ctx, conn, []any{"EVENT", subscriptionID, event}); err != nil {
+						return
+					}
+				}
+				if err := wsjson.Write(ctx, conn, []any{"EOSE", subscriptionID}); err != nil {
+					return
+				}
+			}
+		}
+	}))
+}
+
+func newSignedTextNote(t *testing.T, content string) *Event {
+	t.Helper()
+
+	privKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      EventKindTextNote,
+		Tags:      []Tag{},
+		Content:   content,
+	}
+	if err := event.Sign(privKey); err != nil {
+		t.Fatalf("event.Sign() failed: %s", err)
+	}
+	return event
+}
+
+func TestPoolPublish(t *testing.T) {
+	server1 := newPoolTestServer(nil)
+	defer server1.Close()
+	server2 := newPoolTestServer(nil)
+	defer server2.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+	for _, url := range []string{server1.URL, server2.URL} {
+		if err := pool.Add(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results, err := pool.Publish(ctx, newSignedTextNote(t, "short text note"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("unexpected number of results: %d", len(results))
+	}
+	for url, result := range results {
+		if !result.OK {
+			t.Errorf("command result from %s not OK", url)
+		}
+		if result.Message != url[len("http://"):] {
+			t.Errorf("unexpected message from %s: %s", url, result.Message)
+		}
+	}
+}
+
+func TestPoolSubscribe(t *testing.T) {
+	shared := newSignedTextNote(t, "shared text note")
+	server1 := newPoolTestServer([]*Event{shared})
+	defer server1.Close()
+	server2 := newPoolTestServer([]*Event{shared})
+	defer server2.Close()
+	server3 := newPoolTestServer([]*Event{newSignedTextNote(t, "text note")})
+	defer server3.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+	for _, url := range []string{server1.URL, server2.URL, server3.URL} {
+		if err := pool.Add(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if relays := pool.Relays(); len(relays) != 3 {
+		t.Fatalf("unexpected relays: %v", relays)
+	}
+
+	ctx := context.Background()
+	sub, err := pool.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	allReceived := make(chan struct{})
+	var receivedEOSE atomic.Int32
+	go func() {
+		<-sub.EOSE()
+		receivedEOSE.Add(1)
+		<-allReceived
+		cancel()
+	}()
+
+	var (
+		mu       sync.Mutex
+		received = map[string]int{}
+	)
+	err = sub.Receive(ctx, func(_ context.Context, event *Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[event.Content]++
+		if len(received) == 2 && received[event.Content] == 1 {
+			close(allReceived)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, content := range []string{"shared text note", "text note"} {
+		if count := received[content]; count != 1 {
+			t.Errorf("unexpected number of received events %q: %d", content, count)
+		}
+	}
+	if count := receivedEOSE.Load(); count != 1 {
+		t.Fatalf("unexpected number of received EOSE message: %d", count)
+	}
+}
+
+func TestPoolSubscribeClosed(t *testing.T) {
+	pool := NewPool()
+	defer pool.Close()
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			conn, err := websocket.Accept(w, r, nil)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			defer conn.Close(websocket.StatusInternalError, "")
+
+			for {
+				var message []json.RawMessage
+				if err := wsjson.Read(ctx, conn, &message); err != nil {
+					return
+				}
+				var subscriptionID string
+				if err = json.Unmarshal(message[1], &subscriptionID); err != nil {
+					return
+				}
+				if err := wsjson.Write(ctx, conn, []any{"CLOSED", subscriptionID, "restricted: no subscriptions"}); err != nil {
+					return
+				}
+			}
+		}))
+		defer server.Close()
+		if err := pool.Add(server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sub, err := pool.Subscribe(ctx, []Filter{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goroutines := runtime.NumGoroutine()
+	err = sub.Receive(ctx, func(context.Context, *Event) {})
+	var closedErr *ClosedError
+	if !errors.As(err, &closedErr) {
+		t.Fatalf("sub.Receive() returned %v, expected closed error", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("sub.Receive() returned after context is done")
+	}
+
+	// goroutines started by the subscription exit after Receive returns
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d > %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPoolRemove(t *testing.T) {
+	server := newPoolTestServer(nil)
+	defer server.Close()
+
+	pool := NewPool()
+	defer pool.Close()
+	if err := pool.Add(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if relays := pool.Relays(); len(relays) != 1 {
+		t.Fatalf("unexpected relays: %v", relays)
+	}
+
+	if err := pool.Remove(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if relays := pool.Relays(); len(relays) != 0 {
+		t.Fatalf("unexpected relays: %v", relays)
+	}
+	if _, err := pool.Publish(context.Background(), newSignedTextNote(t, "short text note")); err == nil {
+		t.Error("pool.Publish() succeeded with empty pool")
+	}
+}
+
+func TestRecentIDs(t *testing.T) {
+	ids := newRecentIDs(2)
+	for _, tc := range []struct {
+		id       string
+		expected bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"a", false},
+		{"c", true}, // forgets a
+		{"b", false},
+		{"a", true}, // forgets b
+		{"b", true},
+	} {
+		if got := ids.add(tc.id); got != tc.expected {
+			t.Errorf("ids.add(%q) returned %t, expected %t", tc.id, got, tc.expected)
+		}
+	}
+	if n := len(ids.ids); n != 2 {
+		t.Errorf("unexpected number of remembered ids: %d", n)
+	}
+}
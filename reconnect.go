@@ -0,0 +1,69 @@
+package nostr
+
+import "time"
+
+// ConnectionState is the state of the connection to a relay server.
+type ConnectionState int
+
+const (
+	ConnectionStateConnected    ConnectionState = iota // connected to the relay server
+	ConnectionStateDisconnected                        // the connection is lost
+	ConnectionStateConnecting                          // trying to reconnect to the relay server
+	ConnectionStateClosed                              // the client is closed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateConnecting:
+		return "connecting"
+	case ConnectionStateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+const (
+	defaultBackoffInitial    = 500 * time.Millisecond
+	defaultBackoffMax        = 30 * time.Second
+	defaultBackoffMultiplier = 2
+)
+
+// A Backoff is an exponential backoff policy for reconnection.
+// Zero fields are replaced with default values.
+type Backoff struct {
+	// Initial is the delay after the first failed attempt. The default is 500ms.
+	Initial time.Duration
+	// Max is the upper bound of the delay. The default is 30s.
+	Max time.Duration
+	// Multiplier is the factor by which the delay grows after each failed attempt.
+	// The default is 2.
+	Multiplier float64
+}
+
+func (b *Backoff) initial() time.Duration {
+	if b.Initial > 0 {
+		return b.Initial
+	}
+	return defaultBackoffInitial
+}
+
+func (b *Backoff) next(delay time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+	max := b.Max
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+
+	next := time.Duration(float64(delay) * multiplier)
+	if next > max || next <= 0 {
+		return max
+	}
+	return next
+}
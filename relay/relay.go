@@ -0,0 +1,443 @@
+// Package relay implements a Nostr relay server.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/store"
+	"nhooyr.io/websocket"
+)
+
+const (
+	maxMessageLength = 1 << 20
+	writeTimeout     = 10 * time.Second
+
+	// sendQueueSize is the number of broadcast events queued for each connection.
+	// Connections are closed when clients do not keep up with the queue.
+	sendQueueSize = 256
+)
+
+// ErrDuplicate is returned by Store.Save when the event is already stored.
+// It is the same error as store.ErrDuplicate.
+var ErrDuplicate = store.ErrDuplicate
+
+// A Store persists events received by a relay server.
+// Every store.EventStore implements Store.
+type Store interface {
+	// Save stores the event.
+	// It returns ErrDuplicate if the event is already stored.
+	// It is expected to keep only the latest version of replaceable and addressable events,
+	// and to return store.ErrOutdated if a newer version is already stored.
+	// Ephemeral events are never passed to Save.
+	Save(ctx context.Context, event *nostr.Event) error
+	// Query returns stored events matching any of the filters,
+	// respecting the limit of each filter.
+	Query(ctx context.Context, filters []nostr.Filter) ([]*nostr.Event, error)
+}
+
+// A Counter is a Store that can count events.
+// Relay servers serve count requests defined in NIP-45 only if the store implements Counter.
+// Every store.EventStore implements Counter.
+type Counter interface {
+	// Count returns the number of stored events matching any of the filters.
+	// The limit of filters is ignored.
+	Count(ctx context.Context, filters []nostr.Filter) (int64, error)
+}
+
+// A Relay is a Nostr relay server.
+// It implements http.Handler and serves websocket connections from clients.
+type Relay struct {
+	store   Store
+	info    []byte // relay information document in JSON
+	infoErr error  // error of encoding the document, returned to requests for it
+
+	mu    sync.Mutex
+	conns map[*conn]struct{}
+}
+
+// An Option configures a Relay.
+type Option func(*Relay)
+
+// WithInfo makes the relay server serve the relay information document defined in NIP-11
+// to HTTP requests accepting application/nostr+json.
+func WithInfo(info *nostr.RelayInfo) Option {
+	return func(r *Relay) {
+		r.info, r.infoErr = json.Marshal(info)
+	}
+}
+
+// New creates a new relay server backed by the given store.
+func New(store Store, opts ...Option) *Relay {
+	r := &Relay{
+		store: store,
+		conns: map[*conn]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if (r.info != nil || r.infoErr != nil) && isRelayInfoRequest(req) {
+		r.serveInfo(w, req)
+		return
+	}
+
+	ws, err := websocket.Accept(w, req, &websocket.AcceptOptions{
+		// clients connect from any origin
+		OriginPatterns: []string{"*"},
+	})
+	if err != nil {
+		return
+	}
+	defer ws.Close(websocket.StatusInternalError, "")
+	ws.SetReadLimit(maxMessageLength)
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	c := &conn{
+		ws:     ws,
+		subs:   map[string]*subscription{},
+		queue:  make(chan *nostr.EventMessage, sendQueueSize),
+		cancel: cancel,
+	}
+	go c.sendLoop(ctx)
+
+	r.mu.Lock()
+	r.conns[c] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, c)
+		r.mu.Unlock()
+	}()
+
+	for {
+		_, b, err := ws.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+				ws.Close(websocket.StatusNormalClosure, "")
+			}
+			return
+		}
+		if err := r.handleMessage(ctx, c, b); err != nil {
+			c.write(ctx, &nostr.NoticeMessage{Message: fmt.Sprintf("error: %s", err)})
+		}
+	}
+}
+
+func isRelayInfoRequest(req *http.Request) bool {
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	if req.Method == http.MethodOptions {
+		// CORS preflight
+		return true
+	}
+	return strings.Contains(req.Header.Get("Accept"), nostr.RelayInfoContentType)
+}
+
+func (r *Relay) serveInfo(w http.ResponseWriter, req *http.Request) {
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Headers", "*")
+	h.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	if req.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.infoErr != nil {
+		http.Error(w, "error: could not encode relay information", http.StatusInternalServerError)
+		return
+	}
+	h.Set("Content-Type", nostr.RelayInfoContentType)
+	w.Write(r.info)
+}
+
+func (r *Relay) handleMessage(ctx context.Context, c *conn, b []byte) error {
+	typ, err := nostr.ParseMessageType(b)
+	if err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+
+	switch typ {
+	case nostr.MessageTypeEvent:
+		var m nostr.EventMessage
+		if err = m.UnmarshalJSON(b); err != nil {
+			return fmt.Errorf("invalid event message: %w", err)
+		}
+		return r.handleEventMessage(ctx, c, &m)
+	case nostr.MessageTypeReq:
+		var m nostr.ReqMessage
+		if err = m.UnmarshalJSON(b); err != nil {
+			return fmt.Errorf("invalid req message: %w", err)
+		}
+		return r.handleReqMessage(ctx, c, &m)
+	case nostr.MessageTypeClose:
+		var m nostr.CloseMessage
+		if err = m.UnmarshalJSON(b); err != nil {
+			return fmt.Errorf("invalid close message: %w", err)
+		}
+		return r.handleCloseMessage(ctx, c, &m)
+	case nostr.MessageTypeCount:
+		var m nostr.CountMessage
+		if err = m.UnmarshalJSON(b); err != nil {
+			return fmt.Errorf("invalid count message: %w", err)
+		}
+		if m.Result != nil {
+			return errors.New("invalid count message: unexpected result")
+		}
+		return r.handleCountMessage(ctx, c, &m)
+	}
+
+	return fmt.Errorf("unsupported message type: %s", typ)
+}
+
+func (r *Relay) handleEventMessage(ctx context.Context, c *conn, m *nostr.EventMessage) error {
+	event := m.Event
+	if err := event.Verify(); err != nil {
+		return c.write(ctx, &nostr.OKMessage{
+			EventID: event.ID,
+			OK:      false,
+			Message: fmt.Sprintf("invalid: %s", err),
+		})
+	}
+
+	if event.Kind.IsEphemeral() {
+		// ephemeral events are not stored
+		if err := c.write(ctx, &nostr.OKMessage{EventID: event.ID, OK: true}); err != nil {
+			return err
+		}
+		r.broadcast(event)
+		return nil
+	}
+
+	if err := r.store.Save(ctx, event); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			return c.write(ctx, &nostr.OKMessage{
+				EventID: event.ID,
+				OK:      true,
+				Message: "duplicate: already have this event",
+			})
+		}
+		if errors.Is(err, store.ErrOutdated) {
+			return c.write(ctx, &nostr.OKMessage{
+				EventID: event.ID,
+				OK:      true,
+				Message: "duplicate: have newer event",
+			})
+		}
+		return c.write(ctx, &nostr.OKMessage{
+			EventID: event.ID,
+			OK:      false,
+			Message: "error: could not save event",
+		})
+	}
+
+	if err := c.write(ctx, &nostr.OKMessage{EventID: event.ID, OK: true}); err != nil {
+		return err
+	}
+	r.broadcast(event)
+	return nil
+}
+
+func (r *Relay) handleReqMessage(ctx context.Context, c *conn, m *nostr.ReqMessage) error {
+	// register the subscription first not to miss events saved during the query
+	c.subscribe(m.SubscriptionID, m.Filters)
+
+	events, err := r.store.Query(ctx, m.Filters)
+	if err != nil {
+		c.unsubscribe(m.SubscriptionID)
+		return c.write(ctx, &nostr.ClosedMessage{
+			SubscriptionID: m.SubscriptionID,
+			Message:        "error: could not query events",
+		})
+	}
+	stored := make(map[string]struct{}, len(events))
+	for _, event := range events {
+		stored[event.ID] = struct{}{}
+		if err := c.write(ctx, &nostr.EventMessage{
+			SubscriptionID: m.SubscriptionID,
+			Event:          event,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := c.write(ctx, &nostr.EOSEMessage{SubscriptionID: m.SubscriptionID}); err != nil {
+		return err
+	}
+	c.start(m.SubscriptionID, stored)
+	return nil
+}
+
+func (r *Relay) handleCloseMessage(ctx context.Context, c *conn, m *nostr.CloseMessage) error {
+	c.unsubscribe(m.SubscriptionID)
+	return nil
+}
+
+func (r *Relay) handleCountMessage(ctx context.Context, c *conn, m *nostr.CountMessage) error {
+	counter, ok := r.store.(Counter)
+	if !ok {
+		return c.write(ctx, &nostr.ClosedMessage{
+			SubscriptionID: m.SubscriptionID,
+			Message:        "error: count is not supported",
+		})
+	}
+
+	n, err := counter.Count(ctx, m.Filters)
+	if err != nil {
+		return c.write(ctx, &nostr.ClosedMessage{
+			SubscriptionID: m.SubscriptionID,
+			Message:        "error: could not count events",
+		})
+	}
+	return c.write(ctx, &nostr.CountMessage{
+		SubscriptionID: m.SubscriptionID,
+		Result:         &nostr.CountResult{Count: n},
+	})
+}
+
+// broadcast sends the event to all subscriptions matching it.
+func (r *Relay) broadcast(event *nostr.Event) {
+	r.mu.Lock()
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		for _, subID := range c.match(event) {
+			c.send(&nostr.EventMessage{
+				SubscriptionID: subID,
+				Event:          event,
+			})
+		}
+	}
+}
+
+// A conn is a websocket connection from a client.
+type conn struct {
+	ws *websocket.Conn
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+
+	queue  chan *nostr.EventMessage // broadcast events to be sent in order
+	cancel func()                   // closes the connection
+}
+
+// send queues the broadcast event without blocking.
+// It closes the connection if the queue is full.
+func (c *conn) send(m *nostr.EventMessage) {
+	select {
+	case c.queue <- m:
+	default:
+		c.cancel()
+	}
+}
+
+// sendLoop writes queued events until ctx is done.
+func (c *conn) sendLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-c.queue:
+			writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err := c.write(writeCtx, m)
+			cancel()
+			if err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+// A subscription is a subscription of a connection.
+// Broadcast events are held back until EOSE has been sent.
+type subscription struct {
+	filters nostr.Filters
+	started bool           // whether EOSE has been sent
+	pending []*nostr.Event // broadcast events held back until EOSE
+}
+
+func (c *conn) subscribe(subID string, filters []nostr.Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs[subID] = &subscription{filters: filters}
+}
+
+// start queues the events held back for the subscription after EOSE
+// except events already sent as stored events,
+// and makes the subscription receive broadcast events directly.
+func (c *conn) start(subID string, stored map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub, ok := c.subs[subID]
+	if !ok || sub.started {
+		return
+	}
+	for _, event := range sub.pending {
+		if _, ok := stored[event.ID]; ok {
+			continue
+		}
+		c.send(&nostr.EventMessage{
+			SubscriptionID: subID,
+			Event:          event,
+		})
+	}
+	sub.started = true
+	sub.pending = nil
+}
+
+func (c *conn) unsubscribe(subID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, subID)
+}
+
+// match returns the IDs of started subscriptions matching the event.
+// The event is held back for subscriptions waiting for EOSE.
+func (c *conn) match(event *nostr.Event) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var subIDs []string
+	for subID, sub := range c.subs {
+		if !sub.filters.Match(event) {
+			continue
+		}
+		if !sub.started {
+			if len(sub.pending) >= sendQueueSize {
+				// the query takes too long to hold back more events
+				c.cancel()
+				continue
+			}
+			sub.pending = append(sub.pending, event)
+			continue
+		}
+		subIDs = append(subIDs, subID)
+	}
+	return subIDs
+}
+
+func (c *conn) write(ctx context.Context, message json.Marshaler) error {
+	b, err := message.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return c.ws.Write(ctx, websocket.MessageText, b)
+}
@@ -0,0 +1,548 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/store"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *store.Memory) {
+	t.Helper()
+	s := store.NewMemory()
+	server := httptest.NewServer(New(s))
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+func newTestClient(t *testing.T, url string) *nostr.Client {
+	t.Helper()
+	client, err := nostr.NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newTextNote(t *testing.T, privKey string, content string, createdAt int64) *nostr.Event {
+	t.Helper()
+	event := &nostr.Event{
+		CreatedAt: createdAt,
+		Kind:      nostr.EventKindTextNote,
+		Tags:      []nostr.Tag{},
+		Content:   content,
+	}
+	if err := event.Sign(privKey); err != nil {
+		t.Fatalf("event.Sign() failed: %s", err)
+	}
+	return event
+}
+
+func TestRelayPublish(t *testing.T) {
+	server, s := newTestServer(t)
+	client := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := newTextNote(t, privKey, "short text note", time.Now().Unix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("valid event", func(t *testing.T) {
+		result, err := client.Publish(ctx, event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.OK {
+			t.Errorf("command result not OK: %s", result.Message)
+		}
+		if n, _ := s.Count(ctx, []nostr.Filter{{}}); n != 1 {
+			t.Errorf("unexpected number of stored events: %d", n)
+		}
+	})
+
+	t.Run("duplicate event", func(t *testing.T) {
+		result, err := client.Publish(ctx, event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.OK {
+			t.Errorf("command result not OK: %s", result.Message)
+		}
+		if !strings.HasPrefix(result.Message, "duplicate:") {
+			t.Errorf("unexpected message: %s", result.Message)
+		}
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		forged := *event
+		forged.Content = "forged text note"
+		result, err := client.Publish(ctx, &forged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.OK {
+			t.Error("command result OK")
+		}
+		if !strings.HasPrefix(result.Message, "invalid:") {
+			t.Errorf("unexpected message: %s", result.Message)
+		}
+		if n, _ := s.Count(ctx, []nostr.Filter{{}}); n != 1 {
+			t.Errorf("unexpected number of stored events: %d", n)
+		}
+	})
+}
+
+func TestRelaySubscribe(t *testing.T) {
+	server, _ := newTestServer(t)
+	publisher := newTestClient(t, server.URL)
+	subscriber := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	stored := newTextNote(t, privKey, "stored text note", now-1)
+	live := newTextNote(t, privKey, "live text note", now)
+	other := &nostr.Event{
+		CreatedAt: now,
+		Kind:      nostr.EventKindReaction,
+		Tags:      []nostr.Tag{},
+		Content:   "+",
+	}
+	if err := other.Sign(privKey); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := publisher.Publish(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := subscriber.Subscribe(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		select {
+		case <-sub.EOSE():
+		case <-ctx.Done():
+			return
+		}
+		for _, event := range []*nostr.Event{other, live} {
+			if _, err := publisher.Publish(ctx, event); err != nil && ctx.Err() == nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	err = sub.Receive(ctx, func(_ context.Context, event *nostr.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event.Content)
+		if len(received) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0] != "stored text note" || received[1] != "live text note" {
+		t.Errorf("unexpected received events: %v", received)
+	}
+}
+
+func TestRelayBroadcastOrder(t *testing.T) {
+	server, _ := newTestServer(t)
+	subscriber := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	events := make([]*nostr.Event, 100)
+	for i := range events {
+		events[i] = newTextNote(t, privKey, strconv.Itoa(i), now)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := subscriber.Subscribe(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		select {
+		case <-sub.EOSE():
+		case <-ctx.Done():
+			return
+		}
+		// send events without waiting for OK messages
+		publisher, _, err := websocket.Dial(ctx, server.URL, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() { publisher.Close(websocket.StatusNormalClosure, "") })
+		go func() {
+			// discard OK messages
+			for {
+				if _, _, err := publisher.Read(ctx); err != nil {
+					return
+				}
+			}
+		}()
+		for _, event := range events {
+			if err := wsjson.Write(ctx, publisher, &nostr.EventMessage{Event: event}); err != nil && ctx.Err() == nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	var received []string
+	err = sub.Receive(ctx, func(_ context.Context, event *nostr.Event) {
+		received = append(received, event.Content)
+		if len(received) == len(events) {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != len(events) {
+		t.Fatalf("unexpected number of received events: %d", len(received))
+	}
+	for i, content := range received {
+		if content != strconv.Itoa(i) {
+			t.Fatalf("events are received out of order: %v", received)
+		}
+	}
+}
+
+// hookStore is a Store which calls hook at the beginning of the first query.
+type hookStore struct {
+	Store
+	once sync.Once
+	hook func()
+}
+
+func (s *hookStore) Query(ctx context.Context, filters []nostr.Filter) ([]*nostr.Event, error) {
+	s.once.Do(s.hook)
+	return s.Store.Query(ctx, filters)
+}
+
+func TestRelaySubscribeDuringQuery(t *testing.T) {
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	saved := newTextNote(t, privKey, "saved during query", now)
+	ephemeral := &nostr.Event{
+		CreatedAt: now,
+		Kind:      20001,
+		Tags:      []nostr.Tag{},
+		Content:   "broadcast during query",
+	}
+	if err := ephemeral.Sign(privKey); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &hookStore{Store: store.NewMemory()}
+	r := New(s)
+	s.hook = func() {
+		// events published by other clients while the query is running
+		if err := s.Store.Save(context.Background(), saved); err != nil {
+			t.Error(err)
+		}
+		r.broadcast(saved)
+		r.broadcast(ephemeral)
+		// give the broadcast events a chance to be sent before the query results
+		time.Sleep(50 * time.Millisecond)
+	}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// read raw messages not to deduplicate events on the client side
+	ws, _, err := websocket.Dial(ctx, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close(websocket.StatusNormalClosure, "")
+	err = wsjson.Write(ctx, ws, &nostr.ReqMessage{
+		SubscriptionID: "sub",
+		Filters:        []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote, 20001}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []string
+	for len(received) < 4 {
+		readCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		var m []json.RawMessage
+		err := wsjson.Read(readCtx, ws, &m)
+		cancel()
+		if err != nil {
+			// no more messages
+			break
+		}
+		var typ string
+		if err := json.Unmarshal(m[0], &typ); err != nil {
+			t.Fatal(err)
+		}
+		if typ != "EVENT" {
+			received = append(received, typ)
+			continue
+		}
+		var event nostr.Event
+		if err := json.Unmarshal(m[2], &event); err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, event.Content)
+	}
+
+	expected := []string{"saved during query", "EOSE", "broadcast during query"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("unexpected received messages: %v", received)
+	}
+}
+
+func TestRelayPublishEphemeral(t *testing.T) {
+	server, s := newTestServer(t)
+	client := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &nostr.Event{
+		CreatedAt: time.Now().Unix(),
+		Kind:      20001,
+		Tags:      []nostr.Tag{},
+		Content:   "ephemeral",
+	}
+	if err := event.Sign(privKey); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := client.Publish(ctx, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK {
+		t.Errorf("command result not OK: %s", result.Message)
+	}
+	if n, _ := s.Count(ctx, []nostr.Filter{{}}); n != 0 {
+		t.Errorf("ephemeral event is stored: %d", n)
+	}
+}
+
+func TestRelayPublishReplaceable(t *testing.T) {
+	server, s := newTestServer(t)
+	client := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	newEvent := func(createdAt int64, content string) *nostr.Event {
+		event := &nostr.Event{
+			CreatedAt: createdAt,
+			Kind:      nostr.EventKindSetMetadata,
+			Tags:      []nostr.Tag{},
+			Content:   content,
+		}
+		if err := event.Sign(privKey); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, event := range []*nostr.Event{newEvent(now-1, `{"name":"old"}`), newEvent(now, `{"name":"new"}`)} {
+		if _, err := client.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := client.Publish(ctx, newEvent(now-2, `{"name":"older"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK || !strings.HasPrefix(result.Message, "duplicate:") {
+		t.Errorf("unexpected command result: %+v", result)
+	}
+
+	events, err := s.Query(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindSetMetadata}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Content != `{"name":"new"}` {
+		t.Errorf("unexpected stored events: %+v", events)
+	}
+}
+
+// failingStore is a Store whose queries always fail.
+type failingStore struct {
+	*store.Memory
+}
+
+func (failingStore) Query(context.Context, []nostr.Filter) ([]*nostr.Event, error) {
+	return nil, errors.New("query failed")
+}
+
+func TestRelaySubscribeQueryError(t *testing.T) {
+	server := httptest.NewServer(New(failingStore{store.NewMemory()}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sub, err := client.Subscribe(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sub.Receive(ctx, func(context.Context, *nostr.Event) {})
+	var closedErr *nostr.ClosedError
+	if !errors.As(err, &closedErr) {
+		t.Fatalf("sub.Receive() returned %v, expected closed error", err)
+	}
+	if closedErr.Reason != "error" {
+		t.Errorf("unexpected closed error: %+v", closedErr)
+	}
+}
+
+func TestRelayCount(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := newTestClient(t, server.URL)
+
+	privKey, err := nostr.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	now := time.Now().Unix()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Publish(ctx, newTextNote(t, privKey, "short text note", now-int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := client.Count(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}, Limit: 1}})
+	if err != nil {
+		t.Fatalf("client.Count() failed: %s", err)
+	}
+	if n != 3 {
+		t.Errorf("client.Count() returned %d, expected 3", n)
+	}
+
+	n, err = client.Count(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindReaction}}})
+	if err != nil {
+		t.Fatalf("client.Count() failed: %s", err)
+	}
+	if n != 0 {
+		t.Errorf("client.Count() returned %d, expected 0", n)
+	}
+}
+
+// queryStore is a Store which does not implement Counter.
+type queryStore struct {
+	Store
+}
+
+func TestRelayCountNotSupported(t *testing.T) {
+	server := httptest.NewServer(New(queryStore{store.NewMemory()}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := client.Count(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}}})
+	var closedErr *nostr.ClosedError
+	if !errors.As(err, &closedErr) {
+		t.Fatalf("client.Count() returned %v, expected closed error", err)
+	}
+}
+
+func TestRelayInfo(t *testing.T) {
+	info := &nostr.RelayInfo{
+		Name:          "test relay",
+		SupportedNIPs: []int{1, 11, 45},
+		Limitation:    &nostr.RelayLimitation{MaxSubscriptions: 10},
+	}
+	server := httptest.NewServer(New(store.NewMemory(), WithInfo(info)))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	fetched, err := client.RelayInfo(ctx)
+	if err != nil {
+		t.Fatalf("client.RelayInfo() failed: %s", err)
+	}
+	if !reflect.DeepEqual(fetched, info) {
+		t.Errorf("client.RelayInfo() returned %+v, expected %+v", fetched, info)
+	}
+
+	// websocket connections are still accepted
+	if _, err := client.Count(ctx, []nostr.Filter{{}}); err != nil {
+		t.Errorf("client.Count() failed: %s", err)
+	}
+}
+
+func TestRelayInfoError(t *testing.T) {
+	r := New(store.NewMemory(), WithInfo(&nostr.RelayInfo{Name: "test relay"}))
+	r.infoErr = errors.New("unsupported value")
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := nostr.FetchRelayInfo(ctx, server.URL); err == nil {
+		t.Errorf("nostr.FetchRelayInfo() succeeded")
+	}
+}
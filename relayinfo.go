@@ -0,0 +1,133 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// RelayInfoContentType is the media type of relay information documents.
+const RelayInfoContentType = "application/nostr+json"
+
+// maxRelayInfoSize is the maximum size of relay information documents.
+const maxRelayInfoSize = 1 << 20
+
+// A RelayInfo is a relay information document defined in NIP-11.
+type RelayInfo struct {
+	Name           string           `json:"name,omitempty"`
+	Description    string           `json:"description,omitempty"`
+	Banner         string           `json:"banner,omitempty"`
+	Icon           string           `json:"icon,omitempty"`
+	PubKey         string           `json:"pubkey,omitempty"`
+	Contact        string           `json:"contact,omitempty"`
+	SupportedNIPs  []int            `json:"supported_nips,omitempty"`
+	Software       string           `json:"software,omitempty"`
+	Version        string           `json:"version,omitempty"`
+	PrivacyPolicy  string           `json:"privacy_policy,omitempty"`
+	TermsOfService string           `json:"terms_of_service,omitempty"`
+	Limitation     *RelayLimitation `json:"limitation,omitempty"`
+	RelayCountries []string         `json:"relay_countries,omitempty"`
+	LanguageTags   []string         `json:"language_tags,omitempty"`
+	Tags           []string         `json:"tags,omitempty"`
+	PostingPolicy  string           `json:"posting_policy,omitempty"`
+	PaymentsURL    string           `json:"payments_url,omitempty"`
+	Fees           *RelayFees       `json:"fees,omitempty"`
+}
+
+// SupportsNIP reports whether the relay server advertises support of the NIP.
+func (i *RelayInfo) SupportsNIP(nip int) bool {
+	for _, n := range i.SupportedNIPs {
+		if n == nip {
+			return true
+		}
+	}
+	return false
+}
+
+// A RelayLimitation is limitations imposed by a relay server on clients.
+// Zero values mean no limitation.
+type RelayLimitation struct {
+	MaxMessageLength    int   `json:"max_message_length,omitempty"`
+	MaxSubscriptions    int   `json:"max_subscriptions,omitempty"`
+	MaxFilters          int   `json:"max_filters,omitempty"`
+	MaxLimit            int   `json:"max_limit,omitempty"`
+	MaxSubIDLength      int   `json:"max_subid_length,omitempty"`
+	MaxEventTags        int   `json:"max_event_tags,omitempty"`
+	MaxContentLength    int   `json:"max_content_length,omitempty"`
+	MinPowDifficulty    int   `json:"min_pow_difficulty,omitempty"`
+	AuthRequired        bool  `json:"auth_required,omitempty"`
+	PaymentRequired     bool  `json:"payment_required,omitempty"`
+	RestrictedWrites    bool  `json:"restricted_writes,omitempty"`
+	CreatedAtLowerLimit int64 `json:"created_at_lower_limit,omitempty"`
+	CreatedAtUpperLimit int64 `json:"created_at_upper_limit,omitempty"`
+	DefaultLimit        int   `json:"default_limit,omitempty"`
+}
+
+// RelayFees are fees charged by a relay server.
+type RelayFees struct {
+	Admission    []RelayFee `json:"admission,omitempty"`
+	Subscription []RelayFee `json:"subscription,omitempty"`
+	Publication  []RelayFee `json:"publication,omitempty"`
+}
+
+// A RelayFee is a fee charged by a relay server.
+type RelayFee struct {
+	Amount int64       `json:"amount"`
+	Unit   string      `json:"unit"`
+	Period int64       `json:"period,omitempty"` // in seconds
+	Kinds  []EventKind `json:"kinds,omitempty"`
+}
+
+// FetchRelayInfo fetches the relay information document of the relay server of url.
+// The websocket URL is converted to the HTTP URL of the same host and path.
+func FetchRelayInfo(ctx context.Context, url string) (*RelayInfo, error) {
+	infoURL, err := relayInfoURL(url)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", RelayInfoContentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch relay information: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch relay information: unexpected status code: %d", resp.StatusCode)
+	}
+	var info RelayInfo
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRelayInfoSize)).Decode(&info); err != nil {
+		return nil, fmt.Errorf("invalid relay information: %w", err)
+	}
+	return &info, nil
+}
+
+// RelayInfo fetches the relay information document of the relay server.
+func (c *Client) RelayInfo(ctx context.Context) (*RelayInfo, error) {
+	return FetchRelayInfo(ctx, c.url)
+}
+
+func relayInfoURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,121 @@
+package nostr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchRelayInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != RelayInfoContentType {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", RelayInfoContentType)
+		w.Write([]byte(`{
+			"name": "JellyFish",
+			"description": "Stay Immortal!",
+			"pubkey": "bf2bee5281149c7c350f5d12ae32f514c7864ff10805182f4178538c2c421007",
+			"contact": "hi@dezh.tech",
+			"supported_nips": [1, 9, 11, 13, 17, 40, 42, 59, 62, 70],
+			"software": "https://github.com/dezh-tech/immortal",
+			"version": "immortal - 0.0.9",
+			"limitation": {
+				"max_message_length": 70000,
+				"max_subscriptions": 350,
+				"max_filters": 10,
+				"max_limit": 5000,
+				"max_event_tags": 2000,
+				"max_content_length": 70000,
+				"auth_required": false,
+				"payment_required": true,
+				"restricted_writes": true
+			},
+			"payments_url": "https://jellyfish.land/relay",
+			"fees": {
+				"subscription": [{"amount": 3000, "unit": "sats", "period": 2628003}]
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// the websocket URL is converted to the HTTP URL
+	info, err := FetchRelayInfo(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("FetchRelayInfo() failed: %s", err)
+	}
+	if info.Name != "JellyFish" || info.Version != "immortal - 0.0.9" || info.PaymentsURL != "https://jellyfish.land/relay" {
+		t.Errorf("unexpected relay information: %+v", info)
+	}
+	if !info.SupportsNIP(42) || info.SupportsNIP(45) {
+		t.Errorf("unexpected supported NIPs: %v", info.SupportedNIPs)
+	}
+	expected := &RelayLimitation{
+		MaxMessageLength: 70000,
+		MaxSubscriptions: 350,
+		MaxFilters:       10,
+		MaxLimit:         5000,
+		MaxEventTags:     2000,
+		MaxContentLength: 70000,
+		PaymentRequired:  true,
+		RestrictedWrites: true,
+	}
+	if !reflect.DeepEqual(info.Limitation, expected) {
+		t.Errorf("unexpected limitation: %+v", info.Limitation)
+	}
+	if info.Fees == nil || len(info.Fees.Subscription) != 1 || info.Fees.Subscription[0].Amount != 3000 {
+		t.Errorf("unexpected fees: %+v", info.Fees)
+	}
+}
+
+func TestFetchRelayInfoError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			w.Write([]byte("<html></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, url := range []string{
+		server.URL + "/not-found",
+		server.URL + "/invalid",
+		"ftp" + strings.TrimPrefix(server.URL, "http"),
+	} {
+		if _, err := FetchRelayInfo(ctx, url); err == nil {
+			t.Errorf("FetchRelayInfo(%s) succeeded", url)
+		}
+	}
+}
+
+func TestRelayInfoURL(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{url: "wss://relay.example.com", expected: "https://relay.example.com"},
+		{url: "ws://localhost:7777/path", expected: "http://localhost:7777/path"},
+		{url: "https://relay.example.com/", expected: "https://relay.example.com/"},
+	} {
+		u, err := relayInfoURL(tc.url)
+		if err != nil {
+			t.Fatalf("relayInfoURL(%s) failed: %s", tc.url, err)
+		}
+		if u != tc.expected {
+			t.Errorf("relayInfoURL(%s) returned %s, expected %s", tc.url, u, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package nostr
+
+import "context"
+
+// A Signer signs events on behalf of the owner of a key.
+// The key may be held in another process or device,
+// so implementations may perform network round trips.
+type Signer interface {
+	// PublicKey returns the public key of the signer in hex.
+	PublicKey(ctx context.Context) (string, error)
+	// SignEvent signs the event.
+	// It sets the ID, PubKey, and Sig fields.
+	SignEvent(ctx context.Context, event *Event) error
+}
+
+// A NIP04Encrypter is a Signer that can also encrypt and decrypt NIP-04 payloads.
+type NIP04Encrypter interface {
+	Signer
+	NIP04Encrypt(ctx context.Context, pubKey, plaintext string) (string, error)
+	NIP04Decrypt(ctx context.Context, pubKey, ciphertext string) (string, error)
+}
+
+// A NIP44Encrypter is a Signer that can also encrypt and decrypt NIP-44 payloads.
+type NIP44Encrypter interface {
+	Signer
+	NIP44Encrypt(ctx context.Context, pubKey, plaintext string) (string, error)
+	NIP44Decrypt(ctx context.Context, pubKey, ciphertext string) (string, error)
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/shota3506/go-nostr"
+)
+
+const (
+	idLength     = 64
+	pubKeyLength = 64
+)
+
+type tagKey struct {
+	name  string
+	value string
+}
+
+// A Memory is an EventStore that keeps events in memory.
+// Events are indexed by ID, author, kind, tag and created_at.
+type Memory struct {
+	mu sync.RWMutex
+
+	byID      map[string]*nostr.Event
+	byAddress map[string]*nostr.Event
+	all       []*nostr.Event // sorted newest first
+	byAuthor  map[string][]*nostr.Event
+	byKind    map[nostr.EventKind][]*nostr.Event
+	byTag     map[tagKey][]*nostr.Event
+}
+
+var _ EventStore = (*Memory)(nil)
+
+// NewMemory creates a new empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		byID:      map[string]*nostr.Event{},
+		byAddress: map[string]*nostr.Event{},
+		byAuthor:  map[string][]*nostr.Event{},
+		byKind:    map[nostr.EventKind][]*nostr.Event{},
+		byTag:     map[tagKey][]*nostr.Event{},
+	}
+}
+
+// Save stores a copy of the event.
+// It replaces an older version of a replaceable or addressable event.
+func (m *Memory) Save(ctx context.Context, event *nostr.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.byID[event.ID]; ok {
+		return ErrDuplicate
+	}
+
+	address := event.Address()
+	if address != "" {
+		if old, ok := m.byAddress[address]; ok {
+			if newer(old, event) {
+				return ErrOutdated
+			}
+			m.delete(old)
+		}
+	}
+
+	e := copyEvent(event)
+	m.byID[e.ID] = e
+	if address != "" {
+		m.byAddress[address] = e
+	}
+	m.all = insert(m.all, e)
+	m.byAuthor[e.PubKey] = insert(m.byAuthor[e.PubKey], e)
+	m.byKind[e.Kind] = insert(m.byKind[e.Kind], e)
+	for _, key := range tagKeys(e) {
+		m.byTag[key] = insert(m.byTag[key], e)
+	}
+	return nil
+}
+
+// copyEvent returns a deep copy of the event not sharing tags with it.
+func copyEvent(event *nostr.Event) *nostr.Event {
+	e := *event
+	if event.Tags != nil {
+		e.Tags = make([]nostr.Tag, len(event.Tags))
+		for i, tag := range event.Tags {
+			e.Tags[i] = append(nostr.Tag(nil), tag...)
+		}
+	}
+	return &e
+}
+
+// Query returns copies of stored events matching any of the filters.
+func (m *Memory) Query(ctx context.Context, filters []nostr.Filter) ([]*nostr.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := m.query(filters, true)
+	for i, e := range events {
+		events[i] = copyEvent(e)
+	}
+	return events, nil
+}
+
+// Delete removes the event with the given ID.
+func (m *Memory) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	m.delete(e)
+	return nil
+}
+
+// Count returns the number of stored events matching any of the filters.
+func (m *Memory) Count(ctx context.Context, filters []nostr.Filter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.query(filters, false))), nil
+}
+
+func (m *Memory) delete(e *nostr.Event) {
+	delete(m.byID, e.ID)
+	if address := e.Address(); address != "" && m.byAddress[address] == e {
+		delete(m.byAddress, address)
+	}
+	m.all = remove(m.all, e)
+	if events := remove(m.byAuthor[e.PubKey], e); len(events) > 0 {
+		m.byAuthor[e.PubKey] = events
+	} else {
+		delete(m.byAuthor, e.PubKey)
+	}
+	if events := remove(m.byKind[e.Kind], e); len(events) > 0 {
+		m.byKind[e.Kind] = events
+	} else {
+		delete(m.byKind, e.Kind)
+	}
+	for _, key := range tagKeys(e) {
+		if events := remove(m.byTag[key], e); len(events) > 0 {
+			m.byTag[key] = events
+		} else {
+			delete(m.byTag, key)
+		}
+	}
+}
+
+func (m *Memory) query(filters []nostr.Filter, limit bool) []*nostr.Event {
+	seen := map[string]struct{}{}
+	var results []*nostr.Event
+	for i := range filters {
+		f := &filters[i]
+		n := 0
+		for _, e := range m.candidates(f) {
+			if f.Until != 0 && e.CreatedAt > f.Until {
+				continue
+			}
+			if f.Since != 0 && e.CreatedAt < f.Since {
+				// candidates are sorted newest first
+				break
+			}
+			if !f.Matches(e) {
+				continue
+			}
+			if limit && f.Limit > 0 && n >= f.Limit {
+				break
+			}
+			n++
+			if _, ok := seen[e.ID]; ok {
+				continue
+			}
+			seen[e.ID] = struct{}{}
+			results = append(results, e)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return newer(results[i], results[j])
+	})
+	return results
+}
+
+// candidates returns events which may match the filter, newest first.
+// It uses the most selective index available for the filter.
+func (m *Memory) candidates(f *nostr.Filter) []*nostr.Event {
+	var lists [][]*nostr.Event
+	size := -1
+	use := func(l [][]*nostr.Event) {
+		n := 0
+		for _, events := range l {
+			n += len(events)
+		}
+		if size < 0 || n < size {
+			lists, size = l, n
+		}
+	}
+
+	if len(f.IDs) > 0 && allLength(f.IDs, idLength) {
+		l := make([][]*nostr.Event, 0, len(f.IDs))
+		for _, id := range f.IDs {
+			if e, ok := m.byID[id]; ok {
+				l = append(l, []*nostr.Event{e})
+			}
+		}
+		use(l)
+	}
+	if len(f.Authors) > 0 && allLength(f.Authors, pubKeyLength) {
+		l := make([][]*nostr.Event, 0, len(f.Authors))
+		for _, author := range f.Authors {
+			l = append(l, m.byAuthor[author])
+		}
+		use(l)
+	}
+	if len(f.Kinds) > 0 {
+		l := make([][]*nostr.Event, 0, len(f.Kinds))
+		for _, kind := range f.Kinds {
+			l = append(l, m.byKind[kind])
+		}
+		use(l)
+	}
+	for _, query := range f.TagQueries() {
+		l := make([][]*nostr.Event, 0, len(query)-1)
+		for _, value := range query[1:] {
+			l = append(l, m.byTag[tagKey{name: query[0], value: value}])
+		}
+		use(l)
+	}
+
+	if size < 0 {
+		return m.all
+	}
+	return merge(lists)
+}
+
+func allLength(values []string, n int) bool {
+	for _, v := range values {
+		if len(v) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// tagKeys returns the distinct index keys of the tags of the event.
+func tagKeys(e *nostr.Event) []tagKey {
+	var keys []tagKey
+	seen := map[tagKey]struct{}{}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		key := tagKey{name: tag[0], value: tag[1]}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// insert inserts the event into the sorted list.
+func insert(events []*nostr.Event, e *nostr.Event) []*nostr.Event {
+	i := sort.Search(len(events), func(i int) bool {
+		return !newer(events[i], e)
+	})
+	events = append(events, nil)
+	copy(events[i+1:], events[i:])
+	events[i] = e
+	return events
+}
+
+// remove removes the event from the sorted list.
+func remove(events []*nostr.Event, e *nostr.Event) []*nostr.Event {
+	i := sort.Search(len(events), func(i int) bool {
+		return !newer(events[i], e)
+	})
+	if i == len(events) || events[i] != e {
+		return events
+	}
+	copy(events[i:], events[i+1:])
+	events[len(events)-1] = nil
+	return events[:len(events)-1]
+}
+
+// merge merges sorted lists into a sorted list without duplicates.
+func merge(lists [][]*nostr.Event) []*nostr.Event {
+	switch len(lists) {
+	case 0:
+		return nil
+	case 1:
+		return lists[0]
+	}
+
+	n := 0
+	for _, l := range lists {
+		n += len(l)
+	}
+	seen := make(map[*nostr.Event]struct{}, n)
+	merged := make([]*nostr.Event, 0, n)
+	for _, l := range lists {
+		for _, e := range l {
+			if _, ok := seen[e]; ok {
+				continue
+			}
+			seen[e] = struct{}{}
+			merged = append(merged, e)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return newer(merged[i], merged[j])
+	})
+	return merged
+}
@@ -0,0 +1,318 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+)
+
+var (
+	alice = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	bob   = "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"
+)
+
+func testID(i int) string {
+	return strings.Repeat(fmt.Sprintf("%02x", i), 32)
+}
+
+// testEvents returns events for testing.
+// The i-th event has ID testID(i).
+func testEvents() []*nostr.Event {
+	return []*nostr.Event{
+		{ID: testID(0), PubKey: alice, CreatedAt: 100, Kind: nostr.EventKindSetMetadata, Tags: []nostr.Tag{}},
+		{ID: testID(1), PubKey: alice, CreatedAt: 200, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "nostr"}}},
+		{ID: testID(2), PubKey: bob, CreatedAt: 300, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"e", testID(1)}, {"p", alice}}},
+		{ID: testID(3), PubKey: bob, CreatedAt: 300, Kind: nostr.EventKindReaction, Tags: []nostr.Tag{{"e", testID(1)}, {"p", alice}}},
+		{ID: testID(4), PubKey: alice, CreatedAt: 400, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "go"}, {"t", "nostr"}}},
+		{ID: testID(5), PubKey: bob, CreatedAt: 500, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "nostr"}, {"t", "nostr"}}},
+	}
+}
+
+func newTestMemory(t *testing.T) *Memory {
+	t.Helper()
+	m := NewMemory()
+	for _, e := range testEvents() {
+		if err := m.Save(context.Background(), e); err != nil {
+			t.Fatalf("m.Save() failed: %s", err)
+		}
+	}
+	return m
+}
+
+func eventIDs(events []*nostr.Event) []string {
+	ids := []string{}
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestMemoryQuery(t *testing.T) {
+	m := newTestMemory(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name     string
+		filters  []nostr.Filter
+		expected []string
+	}{
+		{
+			name:     "no filters",
+			filters:  []nostr.Filter{},
+			expected: []string{},
+		},
+		{
+			name:     "all events newest first",
+			filters:  []nostr.Filter{{}},
+			expected: []string{testID(5), testID(4), testID(2), testID(3), testID(1), testID(0)},
+		},
+		{
+			name:     "ids",
+			filters:  []nostr.Filter{{IDs: []string{testID(1), testID(4), testID(9)}}},
+			expected: []string{testID(4), testID(1)},
+		},
+		{
+			name:     "id prefix",
+			filters:  []nostr.Filter{{IDs: []string{testID(3)[:4]}}},
+			expected: []string{testID(3)},
+		},
+		{
+			name:     "authors",
+			filters:  []nostr.Filter{{Authors: []string{bob}}},
+			expected: []string{testID(5), testID(2), testID(3)},
+		},
+		{
+			name:     "author prefix",
+			filters:  []nostr.Filter{{Authors: []string{alice[:8]}}},
+			expected: []string{testID(4), testID(1), testID(0)},
+		},
+		{
+			name:     "kinds",
+			filters:  []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindSetMetadata, nostr.EventKindReaction}}},
+			expected: []string{testID(3), testID(0)},
+		},
+		{
+			name:     "tag",
+			filters:  []nostr.Filter{{Tags: []nostr.Tag{{"t", "nostr"}}}},
+			expected: []string{testID(5), testID(4), testID(1)},
+		},
+		{
+			name:     "tag values",
+			filters:  []nostr.Filter{{Tags: []nostr.Tag{{"t", "go", "nostr"}}}},
+			expected: []string{testID(5), testID(4), testID(1)},
+		},
+		{
+			name:     "tags of the same name",
+			filters:  []nostr.Filter{{Tags: []nostr.Tag{{"t", "go"}, {"t", "nostr"}}}},
+			expected: []string{testID(5), testID(4), testID(1)},
+		},
+		{
+			name:     "multiple tags",
+			filters:  []nostr.Filter{{Tags: []nostr.Tag{{"e", testID(1)}, {"p", alice}}, Kinds: []nostr.EventKind{nostr.EventKindReaction}}},
+			expected: []string{testID(3)},
+		},
+		{
+			name:     "since and until",
+			filters:  []nostr.Filter{{Since: 200, Until: 400}},
+			expected: []string{testID(4), testID(2), testID(3), testID(1)},
+		},
+		{
+			name:     "limit",
+			filters:  []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}, Limit: 2}},
+			expected: []string{testID(5), testID(4)},
+		},
+		{
+			name:     "limit with since",
+			filters:  []nostr.Filter{{Authors: []string{alice}, Until: 300, Limit: 1}},
+			expected: []string{testID(1)},
+		},
+		{
+			name: "multiple filters",
+			filters: []nostr.Filter{
+				{Authors: []string{alice}, Limit: 1},
+				{Kinds: []nostr.EventKind{nostr.EventKindTextNote}, Limit: 2},
+			},
+			expected: []string{testID(5), testID(4)},
+		},
+		{
+			name: "limit applies to each filter",
+			filters: []nostr.Filter{
+				{Authors: []string{alice}, Limit: 1},
+				{Authors: []string{bob}, Limit: 1},
+			},
+			expected: []string{testID(5), testID(4)},
+		},
+		{
+			name:     "no match",
+			filters:  []nostr.Filter{{Authors: []string{alice}, Kinds: []nostr.EventKind{nostr.EventKindReaction}}},
+			expected: []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := m.Query(ctx, tc.filters)
+			if err != nil {
+				t.Fatalf("m.Query() failed: %s", err)
+			}
+			if got := eventIDs(events); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("m.Query() returned %v, expected %v", got, tc.expected)
+			}
+
+			// results must be consistent with Filter semantics
+			var expected []*nostr.Event
+			for _, e := range testEvents() {
+				if nostr.Filters(tc.filters).Match(e) {
+					expected = append(expected, e)
+				}
+			}
+			for _, e := range events {
+				if !nostr.Filters(tc.filters).Match(e) {
+					t.Errorf("m.Query() returned unmatched event %s", e.ID)
+				}
+			}
+			count, err := m.Count(ctx, tc.filters)
+			if err != nil {
+				t.Fatalf("m.Count() failed: %s", err)
+			}
+			if count != int64(len(expected)) {
+				t.Errorf("m.Count() returned %d, expected %d", count, len(expected))
+			}
+		})
+	}
+}
+
+func TestMemorySave(t *testing.T) {
+	m := newTestMemory(t)
+	ctx := context.Background()
+
+	event := testEvents()[0]
+	if err := m.Save(ctx, event); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("m.Save() returned unexpected error: %v", err)
+	}
+
+	// the store must not be affected by modification of saved events
+	event = &nostr.Event{ID: testID(6), PubKey: alice, CreatedAt: 600, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "nostr"}}}
+	if err := m.Save(ctx, event); err != nil {
+		t.Fatalf("m.Save() failed: %s", err)
+	}
+	event.Kind = nostr.EventKindReaction
+	event.Tags[0][1] = "go"
+	event.Tags = append(event.Tags, nostr.Tag{"p", bob})
+	events, err := m.Query(ctx, []nostr.Filter{{IDs: []string{testID(6)}}})
+	if err != nil {
+		t.Fatalf("m.Query() failed: %s", err)
+	}
+	if len(events) != 1 || events[0].Kind != nostr.EventKindTextNote || !reflect.DeepEqual(events[0].Tags, []nostr.Tag{{"t", "nostr"}}) {
+		t.Errorf("unexpected events: %v", events)
+	}
+
+	// the store must not be affected by modification of queried events
+	events[0].Kind = nostr.EventKindReaction
+	events[0].Tags[0][1] = "go"
+	events, err = m.Query(ctx, []nostr.Filter{{IDs: []string{testID(6)}}})
+	if err != nil {
+		t.Fatalf("m.Query() failed: %s", err)
+	}
+	if len(events) != 1 || events[0].Kind != nostr.EventKindTextNote || !reflect.DeepEqual(events[0].Tags, []nostr.Tag{{"t", "nostr"}}) {
+		t.Errorf("unexpected events: %v", events)
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	m := newTestMemory(t)
+	ctx := context.Background()
+
+	if err := m.Delete(ctx, testID(4)); err != nil {
+		t.Fatalf("m.Delete() failed: %s", err)
+	}
+	if err := m.Delete(ctx, testID(4)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("m.Delete() returned unexpected error: %v", err)
+	}
+
+	for _, filter := range []nostr.Filter{
+		{},
+		{IDs: []string{testID(4)}},
+		{Authors: []string{alice}},
+		{Kinds: []nostr.EventKind{nostr.EventKindTextNote}},
+		{Tags: []nostr.Tag{{"t", "go"}}},
+	} {
+		events, err := m.Query(ctx, []nostr.Filter{filter})
+		if err != nil {
+			t.Fatalf("m.Query() failed: %s", err)
+		}
+		for _, e := range events {
+			if e.ID == testID(4) {
+				t.Errorf("deleted event is returned for filter %+v", filter)
+			}
+		}
+	}
+	if _, ok := m.byTag[tagKey{name: "t", value: "go"}]; ok {
+		t.Error("empty tag index is not removed")
+	}
+
+	// the event can be saved again
+	if err := m.Save(ctx, testEvents()[4]); err != nil {
+		t.Fatalf("m.Save() failed: %s", err)
+	}
+}
+
+func TestMemorySaveReplaceable(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name  string
+		older *nostr.Event
+		newer *nostr.Event
+		other *nostr.Event
+	}{
+		{
+			name:  "replaceable",
+			older: &nostr.Event{ID: testID(10), PubKey: alice, CreatedAt: 100, Kind: nostr.EventKindContacts},
+			newer: &nostr.Event{ID: testID(11), PubKey: alice, CreatedAt: 200, Kind: nostr.EventKindContacts},
+			other: &nostr.Event{ID: testID(12), PubKey: bob, CreatedAt: 100, Kind: nostr.EventKindContacts},
+		},
+		{
+			name:  "addressable",
+			older: &nostr.Event{ID: testID(10), PubKey: alice, CreatedAt: 100, Kind: 30023, Tags: []nostr.Tag{{"d", "article"}}},
+			newer: &nostr.Event{ID: testID(11), PubKey: alice, CreatedAt: 200, Kind: 30023, Tags: []nostr.Tag{{"d", "article"}}},
+			other: &nostr.Event{ID: testID(12), PubKey: alice, CreatedAt: 100, Kind: 30023, Tags: []nostr.Tag{{"d", "other"}}},
+		},
+		{
+			name:  "same created_at",
+			older: &nostr.Event{ID: testID(11), PubKey: alice, CreatedAt: 100, Kind: 10002},
+			newer: &nostr.Event{ID: testID(10), PubKey: alice, CreatedAt: 100, Kind: 10002},
+			other: &nostr.Event{ID: testID(12), PubKey: alice, CreatedAt: 100, Kind: 10003},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMemory()
+			for _, e := range []*nostr.Event{tc.other, tc.older, tc.newer} {
+				if err := m.Save(ctx, e); err != nil {
+					t.Fatalf("m.Save() failed: %s", err)
+				}
+			}
+			if err := m.Save(ctx, tc.older); !errors.Is(err, ErrOutdated) {
+				t.Errorf("m.Save() returned unexpected error: %v", err)
+			}
+			if err := m.Save(ctx, tc.newer); !errors.Is(err, ErrDuplicate) {
+				t.Errorf("m.Save() returned unexpected error: %v", err)
+			}
+
+			events, err := m.Query(ctx, []nostr.Filter{{}})
+			if err != nil {
+				t.Fatalf("m.Query() failed: %s", err)
+			}
+			expected := eventIDs([]*nostr.Event{tc.newer, tc.other})
+			if tc.newer.CreatedAt == tc.other.CreatedAt && tc.other.ID < tc.newer.ID {
+				expected = eventIDs([]*nostr.Event{tc.other, tc.newer})
+			}
+			if got := eventIDs(events); !reflect.DeepEqual(got, expected) {
+				t.Errorf("m.Query() returned %v, expected %v", got, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+// Package sqlite provides an event store backed by SQLite.
+// It uses a pure-Go SQLite driver and does not require cgo.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/store"
+	_ "modernc.org/sqlite" // register the driver
+)
+
+const (
+	idLength     = 64
+	pubKeyLength = 64
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	id         TEXT    NOT NULL PRIMARY KEY,
+	pubkey     TEXT    NOT NULL,
+	created_at INTEGER NOT NULL,
+	kind       INTEGER NOT NULL,
+	tags       TEXT    NOT NULL,
+	content    TEXT    NOT NULL,
+	sig        TEXT    NOT NULL,
+	address    TEXT
+);
+CREATE INDEX IF NOT EXISTS events_created_at ON events (created_at DESC, id);
+CREATE INDEX IF NOT EXISTS events_pubkey ON events (pubkey, created_at DESC);
+CREATE INDEX IF NOT EXISTS events_kind ON events (kind, created_at DESC);
+CREATE INDEX IF NOT EXISTS events_address ON events (address) WHERE address IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS tags (
+	event_id TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	value    TEXT NOT NULL,
+	PRIMARY KEY (event_id, name, value)
+);
+CREATE INDEX IF NOT EXISTS tags_name_value ON tags (name, value);
+`
+
+// A Store is an EventStore backed by a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+var _ store.EventStore = (*Store)(nil)
+
+// Open opens the SQLite database file at path and prepares the schema.
+// The file is created if it does not exist.
+// The path ":memory:" opens an in-memory database, which is lost when the store is closed.
+func Open(path string) (*Store, error) {
+	dsn := url.URL{
+		Scheme: "file",
+		// SQLite decodes percent-encoded characters in file names
+		Opaque: (&url.URL{Path: path}).EscapedPath(),
+		RawQuery: url.Values{
+			"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)"},
+			"_txlock": {"immediate"},
+		}.Encode(),
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// every connection to ":memory:" opens its own database
+		db.SetMaxOpenConns(1)
+	}
+	s, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New creates a store with the given database and prepares the schema.
+// The database must be opened with the "sqlite" driver.
+// Unlike Open, New does not configure the database. Concurrent writers need
+// busy_timeout to wait for locks instead of failing with SQLITE_BUSY,
+// _txlock=immediate for Save to take the write lock before reading,
+// and journal_mode(WAL) lets queries run during writes:
+//
+//	file:events.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate
+//
+// An in-memory database must be limited to one connection with db.SetMaxOpenConns(1).
+func New(db *sql.DB) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("could not create schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save stores the event.
+// It replaces an older version of a replaceable or addressable event.
+func (s *Store) Save(ctx context.Context, event *nostr.Event) error {
+	tags := event.Tags
+	if tags == nil {
+		tags = []nostr.Tag{}
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE id = ?)`, event.ID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrDuplicate
+	}
+
+	var address sql.NullString
+	if a := event.Address(); a != "" {
+		address = sql.NullString{String: a, Valid: true}
+
+		var (
+			oldID        string
+			oldCreatedAt int64
+		)
+		err := tx.QueryRowContext(ctx, `SELECT id, created_at FROM events WHERE address = ?`, a).Scan(&oldID, &oldCreatedAt)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		case oldCreatedAt > event.CreatedAt || (oldCreatedAt == event.CreatedAt && oldID < event.ID):
+			return store.ErrOutdated
+		default:
+			if err := deleteEvent(ctx, tx, oldID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO events (id, pubkey, created_at, kind, tags, content, sig, address) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.ID, event.PubKey, event.CreatedAt, int64(event.Kind), string(b), event.Content, event.Sig, address,
+	); err != nil {
+		return err
+	}
+
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO tags (event_id, name, value) VALUES (?, ?, ?)`,
+			event.ID, tag[0], tag[1],
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Query returns stored events matching any of the filters.
+func (s *Store) Query(ctx context.Context, filters []nostr.Filter) ([]*nostr.Event, error) {
+	if len(filters) == 0 {
+		return []*nostr.Event{}, nil
+	}
+
+	query, args := buildQuery(filters, true)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, pubkey, created_at, kind, tags, content, sig FROM (`+query+`) ORDER BY created_at DESC, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*nostr.Event{}
+	for rows.Next() {
+		var (
+			e    nostr.Event
+			tags string
+		)
+		if err := rows.Scan(&e.ID, &e.PubKey, &e.CreatedAt, &e.Kind, &tags, &e.Content, &e.Sig); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &e.Tags); err != nil {
+			return nil, fmt.Errorf("invalid tags of event %s: %w", e.ID, err)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Delete removes the event with the given ID.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	if err := deleteEvent(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteEvent(ctx context.Context, tx *sql.Tx, id string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE event_id = ?`, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Count returns the number of stored events matching any of the filters.
+func (s *Store) Count(ctx context.Context, filters []nostr.Filter) (int64, error) {
+	if len(filters) == 0 {
+		return 0, nil
+	}
+
+	query, args := buildQuery(filters, false)
+	var n int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM (`+query+`)`, args...).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// buildQuery builds a query selecting events matching any of the filters.
+func buildQuery(filters []nostr.Filter, limit bool) (string, []any) {
+	var (
+		selects []string
+		args    []any
+	)
+	for i := range filters {
+		q, a := buildFilterQuery(&filters[i], limit)
+		selects = append(selects, `SELECT * FROM (`+q+`)`)
+		args = append(args, a...)
+	}
+	return strings.Join(selects, ` UNION `), args
+}
+
+func buildFilterQuery(f *nostr.Filter, limit bool) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	if len(f.IDs) > 0 {
+		c, a := prefixCondition("id", f.IDs, idLength)
+		conds = append(conds, c)
+		args = append(args, a...)
+	}
+	if len(f.Authors) > 0 {
+		c, a := prefixCondition("pubkey", f.Authors, pubKeyLength)
+		conds = append(conds, c)
+		args = append(args, a...)
+	}
+	if len(f.Kinds) > 0 {
+		conds = append(conds, `kind IN (`+placeholders(len(f.Kinds))+`)`)
+		for _, kind := range f.Kinds {
+			args = append(args, int64(kind))
+		}
+	}
+	if f.Since != 0 {
+		conds = append(conds, `created_at >= ?`)
+		args = append(args, f.Since)
+	}
+	if f.Until != 0 {
+		conds = append(conds, `created_at <= ?`)
+		args = append(args, f.Until)
+	}
+	for _, query := range f.TagQueries() {
+		if len(query) == 1 {
+			// no value can match
+			conds = append(conds, `0`)
+			continue
+		}
+		conds = append(conds, `id IN (SELECT event_id FROM tags WHERE name = ? AND value IN (`+placeholders(len(query)-1)+`))`)
+		args = append(args, query[0])
+		for _, value := range query[1:] {
+			args = append(args, value)
+		}
+	}
+
+	q := `SELECT id, pubkey, created_at, kind, tags, content, sig FROM events`
+	if len(conds) > 0 {
+		q += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+	if limit && f.Limit > 0 {
+		q += ` ORDER BY created_at DESC, id LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	return q, args
+}
+
+// prefixCondition returns a condition matching values of the column starting with any of the prefixes.
+func prefixCondition(column string, prefixes []string, length int) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	for _, prefix := range prefixes {
+		if len(prefix) == length {
+			conds = append(conds, column+` = ?`)
+			args = append(args, prefix)
+			continue
+		}
+		conds = append(conds, `substr(`+column+`, 1, ?) = ?`)
+		args = append(args, len(prefix), prefix)
+	}
+	return `(` + strings.Join(conds, ` OR `) + `)`, args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
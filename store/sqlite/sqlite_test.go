@@ -0,0 +1,306 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shota3506/go-nostr"
+	"github.com/shota3506/go-nostr/store"
+)
+
+var (
+	alice = "7e7e9c42a91bfef19fa929e5fda1b72e0ebc1a4c1141673e2794234d86addf4e"
+	bob   = "a0b6a49f2b3d68be2c89df926f58579b974014c091f4d945e8e3de7f3f87bbc4"
+)
+
+func testID(i int) string {
+	return strings.Repeat(fmt.Sprintf("%02x", i), 32)
+}
+
+func testEvents() []*nostr.Event {
+	return []*nostr.Event{
+		{ID: testID(0), PubKey: alice, CreatedAt: 100, Kind: nostr.EventKindSetMetadata, Tags: []nostr.Tag{}, Content: "{}", Sig: "sig0"},
+		{ID: testID(1), PubKey: alice, CreatedAt: 200, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "nostr"}}, Content: "hello", Sig: "sig1"},
+		{ID: testID(2), PubKey: bob, CreatedAt: 300, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"e", testID(1), "wss://relay.example.com"}, {"p", alice}}, Content: "reply", Sig: "sig2"},
+		{ID: testID(3), PubKey: bob, CreatedAt: 300, Kind: nostr.EventKindReaction, Tags: []nostr.Tag{{"e", testID(1)}, {"p", alice}}, Content: "+", Sig: "sig3"},
+		{ID: testID(4), PubKey: alice, CreatedAt: 400, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "go"}, {"t", "nostr"}}, Content: "go", Sig: "sig4"},
+		{ID: testID(5), PubKey: bob, CreatedAt: 500, Kind: nostr.EventKindTextNote, Tags: []nostr.Tag{{"t", "nostr"}, {"t", "nostr"}, {"x"}}, Content: "100%_done", Sig: "sig5"},
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	for _, e := range testEvents() {
+		if err := s.Save(context.Background(), e); err != nil {
+			t.Fatalf("s.Save() failed: %s", err)
+		}
+	}
+	return s
+}
+
+func TestStoreQuery(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// the in-memory store is the reference implementation
+	m := store.NewMemory()
+	for _, e := range testEvents() {
+		if err := m.Save(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		filters []nostr.Filter
+	}{
+		{"no filters", []nostr.Filter{}},
+		{"all events", []nostr.Filter{{}}},
+		{"ids", []nostr.Filter{{IDs: []string{testID(1), testID(4), testID(9)}}}},
+		{"id prefix", []nostr.Filter{{IDs: []string{testID(3)[:4]}}}},
+		{"uppercase id prefix", []nostr.Filter{{IDs: []string{strings.ToUpper(alice[:4])}}}},
+		{"authors", []nostr.Filter{{Authors: []string{bob}}}},
+		{"author prefix", []nostr.Filter{{Authors: []string{alice[:8]}}}},
+		{"wildcard author", []nostr.Filter{{Authors: []string{"%"}}}},
+		{"kinds", []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindSetMetadata, nostr.EventKindReaction}}}},
+		{"tag", []nostr.Filter{{Tags: []nostr.Tag{{"t", "nostr"}}}}},
+		{"tag values", []nostr.Filter{{Tags: []nostr.Tag{{"t", "go", "nostr"}}}}},
+		{"tag without values", []nostr.Filter{{Tags: []nostr.Tag{{"x"}}}}},
+		{"tags of the same name", []nostr.Filter{{Tags: []nostr.Tag{{"t", "go"}, {"t", "nostr"}}}}},
+		{"multiple tags", []nostr.Filter{{Tags: []nostr.Tag{{"e", testID(1)}, {"p", alice}}, Kinds: []nostr.EventKind{nostr.EventKindReaction}}}},
+		{"since and until", []nostr.Filter{{Since: 200, Until: 400}}},
+		{"limit", []nostr.Filter{{Kinds: []nostr.EventKind{nostr.EventKindTextNote}, Limit: 2}}},
+		{"limit with until", []nostr.Filter{{Authors: []string{alice}, Until: 300, Limit: 1}}},
+		{"limit with ties", []nostr.Filter{{Since: 300, Until: 300, Limit: 1}}},
+		{"multiple filters", []nostr.Filter{{Authors: []string{alice}, Limit: 1}, {Kinds: []nostr.EventKind{nostr.EventKindTextNote}, Limit: 2}}},
+		{"limit applies to each filter", []nostr.Filter{{Authors: []string{alice}, Limit: 1}, {Authors: []string{bob}, Limit: 1}}},
+		{"no match", []nostr.Filter{{Authors: []string{alice}, Kinds: []nostr.EventKind{nostr.EventKindReaction}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, err := m.Query(ctx, tc.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, err := s.Query(ctx, tc.filters)
+			if err != nil {
+				t.Fatalf("s.Query() failed: %s", err)
+			}
+			if len(events) != len(expected) {
+				t.Fatalf("s.Query() returned %d events, expected %d", len(events), len(expected))
+			}
+			for i := range events {
+				if !reflect.DeepEqual(events[i], expected[i]) {
+					t.Errorf("s.Query() returned %+v at %d, expected %+v", events[i], i, expected[i])
+				}
+			}
+
+			expectedCount, err := m.Count(ctx, tc.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, err := s.Count(ctx, tc.filters)
+			if err != nil {
+				t.Fatalf("s.Count() failed: %s", err)
+			}
+			if count != expectedCount {
+				t.Errorf("s.Count() returned %d, expected %d", count, expectedCount)
+			}
+		})
+	}
+}
+
+func TestStoreSave(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, testEvents()[0]); !errors.Is(err, store.ErrDuplicate) {
+		t.Errorf("s.Save() returned unexpected error: %v", err)
+	}
+
+	event := &nostr.Event{ID: testID(6), PubKey: alice, CreatedAt: 600, Kind: nostr.EventKindTextNote}
+	if err := s.Save(ctx, event); err != nil {
+		t.Fatalf("s.Save() failed: %s", err)
+	}
+	events, err := s.Query(ctx, []nostr.Filter{{IDs: []string{testID(6)}}})
+	if err != nil {
+		t.Fatalf("s.Query() failed: %s", err)
+	}
+	if len(events) != 1 || events[0].Tags == nil {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestStoreDelete(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, testID(4)); err != nil {
+		t.Fatalf("s.Delete() failed: %s", err)
+	}
+	if err := s.Delete(ctx, testID(4)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("s.Delete() returned unexpected error: %v", err)
+	}
+
+	count, err := s.Count(ctx, []nostr.Filter{{Tags: []nostr.Tag{{"t", "go"}}}})
+	if err != nil {
+		t.Fatalf("s.Count() failed: %s", err)
+	}
+	if count != 0 {
+		t.Errorf("deleted event is counted: %d", count)
+	}
+
+	if err := s.Save(ctx, testEvents()[4]); err != nil {
+		t.Fatalf("s.Save() failed: %s", err)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	ctx := context.Background()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %s", err)
+	}
+	for _, e := range testEvents() {
+		if err := s.Save(ctx, e); err != nil {
+			t.Fatalf("s.Save() failed: %s", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %s", err)
+	}
+	defer s.Close()
+	count, err := s.Count(ctx, []nostr.Filter{{}})
+	if err != nil {
+		t.Fatalf("s.Count() failed: %s", err)
+	}
+	if count != int64(len(testEvents())) {
+		t.Errorf("unexpected number of events after reopen: %d", count)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path string
+	}{
+		{"special characters", filepath.Join(t.TempDir(), "events?mode=ro#1%.db")},
+		{"memory", ":memory:"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Open(tc.path)
+			if err != nil {
+				t.Fatalf("Open() failed: %s", err)
+			}
+			defer s.Close()
+
+			// save and count concurrently on the pooled connections
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for _, e := range testEvents() {
+				wg.Add(1)
+				go func(e *nostr.Event) {
+					defer wg.Done()
+					if err := s.Save(ctx, e); err != nil {
+						t.Errorf("s.Save() failed: %s", err)
+					}
+					if _, err := s.Count(ctx, []nostr.Filter{{}}); err != nil {
+						t.Errorf("s.Count() failed: %s", err)
+					}
+				}(e)
+			}
+			wg.Wait()
+
+			count, err := s.Count(ctx, []nostr.Filter{{}})
+			if err != nil {
+				t.Fatalf("s.Count() failed: %s", err)
+			}
+			if count != int64(len(testEvents())) {
+				t.Errorf("unexpected number of events: %d", count)
+			}
+		})
+	}
+
+	t.Run("memory connections", func(t *testing.T) {
+		s, err := Open(":memory:")
+		if err != nil {
+			t.Fatalf("Open() failed: %s", err)
+		}
+		defer s.Close()
+
+		// another connection would open an empty database
+		if n := s.db.Stats().MaxOpenConnections; n != 1 {
+			t.Errorf("unexpected max open connections: %d", n)
+		}
+	})
+
+	t.Run("file name", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := Open(filepath.Join(dir, "events?#.db"))
+		if err != nil {
+			t.Fatalf("Open() failed: %s", err)
+		}
+		s.Close()
+
+		if _, err := os.Stat(filepath.Join(dir, "events?#.db")); err != nil {
+			t.Errorf("database file is not created: %s", err)
+		}
+	})
+}
+
+func TestStoreSaveReplaceable(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	older := &nostr.Event{ID: testID(10), PubKey: alice, CreatedAt: 100, Kind: 30023, Tags: []nostr.Tag{{"d", "article"}, {"t", "nostr"}}}
+	newer := &nostr.Event{ID: testID(11), PubKey: alice, CreatedAt: 200, Kind: 30023, Tags: []nostr.Tag{{"d", "article"}}}
+	other := &nostr.Event{ID: testID(12), PubKey: alice, CreatedAt: 100, Kind: 30023, Tags: []nostr.Tag{{"d", "other"}}}
+
+	for _, e := range []*nostr.Event{other, older, newer} {
+		if err := s.Save(ctx, e); err != nil {
+			t.Fatalf("s.Save() failed: %s", err)
+		}
+	}
+	if err := s.Save(ctx, older); !errors.Is(err, store.ErrOutdated) {
+		t.Errorf("s.Save() returned unexpected error: %v", err)
+	}
+	if err := s.Save(ctx, newer); !errors.Is(err, store.ErrDuplicate) {
+		t.Errorf("s.Save() returned unexpected error: %v", err)
+	}
+
+	events, err := s.Query(ctx, []nostr.Filter{{Kinds: []nostr.EventKind{30023}}})
+	if err != nil {
+		t.Fatalf("s.Query() failed: %s", err)
+	}
+	if len(events) != 2 || events[0].ID != newer.ID || events[1].ID != other.ID {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// tags of the replaced event are removed
+	count, err := s.Count(ctx, []nostr.Filter{{Tags: []nostr.Tag{{"t", "nostr"}}, Kinds: []nostr.EventKind{30023}}})
+	if err != nil {
+		t.Fatalf("s.Count() failed: %s", err)
+	}
+	if count != 0 {
+		t.Errorf("replaced event is counted: %d", count)
+	}
+}
@@ -0,0 +1,46 @@
+// Package store provides storage of Nostr events
+// for relay servers and client-side caches.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shota3506/go-nostr"
+)
+
+var (
+	// ErrDuplicate is returned when the event is already stored.
+	ErrDuplicate = errors.New("duplicate event")
+	// ErrNotFound is returned when the event is not stored.
+	ErrNotFound = errors.New("event not found")
+	// ErrOutdated is returned when a newer version of a replaceable or addressable event is already stored.
+	ErrOutdated = errors.New("newer event already stored")
+)
+
+// An EventStore stores events and queries them with filters.
+type EventStore interface {
+	// Save stores the event.
+	// It returns ErrDuplicate if the event is already stored.
+	// For replaceable and addressable events, only the latest event for each address is kept:
+	// an older stored event is deleted, and ErrOutdated is returned if a newer one is already stored.
+	Save(ctx context.Context, event *nostr.Event) error
+	// Query returns stored events matching any of the filters, newest first.
+	// Events with the same created_at are ordered by ID.
+	// The limit of each filter is applied to the events matching that filter.
+	Query(ctx context.Context, filters []nostr.Filter) ([]*nostr.Event, error)
+	// Delete removes the event with the given ID.
+	// It returns ErrNotFound if the event is not stored.
+	Delete(ctx context.Context, id string) error
+	// Count returns the number of stored events matching any of the filters.
+	// The limit of filters is ignored.
+	Count(ctx context.Context, filters []nostr.Filter) (int64, error)
+}
+
+// newer reports whether a should be ordered before b.
+func newer(a, b *nostr.Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
@@ -2,15 +2,58 @@ package nostr
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	mergedEventBufferSize = 100
+
+	// mergedSeenIDsSize is the number of recent event IDs remembered
+	// to deliver events received from several subscriptions only once.
+	mergedSeenIDsSize = 10000
 )
 
+// A ClosedError is returned by Subscription.Receive and Client.CountResult
+// when the relay server ends the subscription or refuses the request with a CLOSED message.
+type ClosedError struct {
+	SubscriptionID string
+	// Reason is the machine-readable prefix of the message.
+	// It is empty if the message does not have a prefix.
+	Reason Reason
+	// Message is the human-readable message following the prefix.
+	Message string
+}
+
+func newClosedError(m *ClosedMessage) *ClosedError {
+	reason, message := ParseReason(m.Message)
+	return &ClosedError{
+		SubscriptionID: m.SubscriptionID,
+		Reason:         reason,
+		Message:        message,
+	}
+}
+
+func (e *ClosedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("subscription closed by relay server: %s", e.Message)
+	}
+	return fmt.Sprintf("subscription closed by relay server: %s: %s", e.Reason, e.Message)
+}
+
 // A Subscription is a subscription to a channel.
 type Subscription struct {
 	id string
 
 	eventChan <-chan *Event
 	eoseChan  <-chan struct{}
+	errChan   <-chan error
+	dropped   func() int64
 
 	trigger func(context.Context) error
 	closer  func(context.Context) error
@@ -23,6 +66,7 @@ func (s *Subscription) ID() string {
 
 // Receive calls f for each event received from the subscription.
 // If ctx is done, Receive returns nil.
+// If the subscription is closed by the relay server, Receive returns a *ClosedError.
 //
 // The context passed to f will be canceled when ctx is Done or there is a fatal service error.
 func (s *Subscription) Receive(ctx context.Context, f func(context.Context, *Event)) error {
@@ -47,19 +91,186 @@ func (s *Subscription) Receive(ctx context.Context, f func(context.Context, *Eve
 		return err
 	}
 
-	defer func() {
-		// use new context to close subscription
-		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		s.closer(closeCtx)
-	}()
+	select {
+	case <-ctx.Done():
+	case err := <-s.errChan:
+		// the subscription has already been closed on the server side
+		return err
+	}
 
-	<-ctx.Done()
+	// use new context to close subscription
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer closeCancel()
+	s.closer(closeCtx)
 
 	return nil
 }
 
+// Dropped returns the number of events dropped
+// because the buffer of the subscription was full.
+func (s *Subscription) Dropped() int64 {
+	if s.dropped == nil {
+		return 0
+	}
+	return s.dropped()
+}
+
 // EOSE returns a channel that recieves a value at the end of stored events.
 func (s *Subscription) EOSE() <-chan struct{} {
 	return s.eoseChan
 }
+
+// mergeSubscriptions returns a subscription that receives events of all the subscriptions.
+// Duplicate events are delivered only once, and EOSE is sent after all the subscriptions have sent it.
+// If failFast is true, the subscription is closed with the first error of any of the subscriptions,
+// otherwise it is closed with the errors after all of them have failed.
+func mergeSubscriptions(subs []*Subscription, failFast bool) *Subscription {
+	// buffer events so that a busy receiver does not block all subscriptions at once
+	eventChan := make(chan *Event, mergedEventBufferSize)
+	eoseChan := make(chan struct{}, 1)
+	errChan := make(chan error, 1)
+
+	var (
+		wg     sync.WaitGroup
+		cancel context.CancelFunc
+	)
+
+	trigger := func(ctx context.Context) error {
+		var innerCtx context.Context
+		innerCtx, cancel = context.WithCancel(ctx)
+
+		seen := newRecentIDs(mergedSeenIDsSize)
+		var remaining atomic.Int32
+		remaining.Store(int32(len(subs)))
+
+		var (
+			errMu  sync.Mutex
+			errs   []error
+			closed bool
+		)
+
+		for _, sub := range subs {
+			var once sync.Once
+			finish := func() {
+				once.Do(func() {
+					if remaining.Add(-1) == 0 {
+						select {
+						case eoseChan <- struct{}{}:
+						default:
+						}
+					}
+				})
+			}
+
+			wg.Add(2)
+			go func(sub *Subscription) {
+				defer wg.Done()
+				select {
+				case <-sub.EOSE():
+					finish()
+				case <-innerCtx.Done():
+				}
+			}(sub)
+			go func(sub *Subscription) {
+				defer wg.Done()
+				err := sub.Receive(innerCtx, func(ctx context.Context, event *Event) {
+					if !seen.add(event.ID) {
+						return
+					}
+					select {
+					case eventChan <- event:
+					case <-ctx.Done():
+					}
+				})
+				if err != nil {
+					// the subscription will never receive stored events
+					finish()
+
+					errMu.Lock()
+					errs = append(errs, err)
+					if !closed && (failFast || len(errs) == len(subs)) {
+						closed = true
+						// stop the other subscriptions and watching EOSE
+						// as Receive returns without calling closer
+						cancel()
+						if failFast {
+							errChan <- err
+						} else {
+							errChan <- errors.Join(errs...)
+						}
+					}
+					errMu.Unlock()
+				}
+			}(sub)
+		}
+		return nil
+	}
+
+	closer := func(ctx context.Context) error {
+		if cancel != nil {
+			cancel()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return nil
+		}
+	}
+
+	return &Subscription{
+		id:        uuid.New().String(),
+		eventChan: eventChan,
+		eoseChan:  eoseChan,
+		errChan:   errChan,
+		dropped: func() int64 {
+			var n int64
+			for _, sub := range subs {
+				n += sub.Dropped()
+			}
+			return n
+		},
+		trigger: trigger,
+		closer:  closer,
+	}
+}
+
+// recentIDs is a set of event IDs which forgets the oldest ID when it is full.
+type recentIDs struct {
+	mu   sync.Mutex
+	ids  map[string]struct{}
+	ring []string // IDs in the order of addition
+	next int      // index of the oldest ID in ring when it is full
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{
+		ids:  make(map[string]struct{}, size),
+		ring: make([]string, 0, size),
+	}
+}
+
+// add adds the ID to the set. It reports false if the ID is already in the set.
+func (r *recentIDs) add(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ids[id]; ok {
+		return false
+	}
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, id)
+	} else {
+		delete(r.ids, r.ring[r.next])
+		r.ring[r.next] = id
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.ids[id] = struct{}{}
+	return true
+}